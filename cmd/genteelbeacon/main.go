@@ -7,18 +7,27 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"math/rand/v2"
+	"net"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/schildwaechter/genteelbeacon/internal/agitator"
 	"github.com/schildwaechter/genteelbeacon/internal/config"
 	"github.com/schildwaechter/genteelbeacon/internal/gearsmith"
 	"github.com/schildwaechter/genteelbeacon/internal/handlers"
+	"github.com/schildwaechter/genteelbeacon/internal/loadgenerator"
 	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/operator"
 	"github.com/schildwaechter/genteelbeacon/internal/services"
 
 	"github.com/ansrivas/fiberprometheus/v2"
@@ -32,20 +41,39 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc"
 )
 
+// the share of GENTEEL_SHUTDOWN_TIMEOUT kept for flushing the telemetry, i.e. a quarter
+const flushShare = 4
+
 func main() {
+	os.Exit(run())
+}
+
+// run serves until told to stop and tells the exit code, having cleaned up after itself
+func run() int {
+	// the log levels may be changed by flags right away
+	_, jsonLogging := os.LookupEnv("JSONLOGGING")
+	if err := o11y.InitLogLevels(jsonLogging); err != nil {
+		log.Fatal("Invalid log level: ", err)
+	}
 	if err := config.Initialize(); err != nil {
 		log.Fatal("Failed to initialize configuration: ", err)
 	}
 
-	// initialize ink and grease channels
-	services.InitInkGreaseChannels()
-	// monitor the ink and grease
-	services.StartInkMonitor()
-	services.StartGreaseMonitor()
-	// refill ink and clean grease
-	services.StartInkGreaseTimers()
+	// stop on SIGTERM (e.g. from Kubernetes) or Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// we stop reporting ready as soon as we start draining on shutdown, the admin API may drain as well
+	var draining atomic.Bool
+
+	// initialize ink, grease and other resources
+	services.InitResources()
+	// monitor the levels, refill ink, clean grease etc. until all requests are done
+	monitorCtx, stopMonitors := context.WithCancel(context.Background())
+	defer stopMonitors()
+	services.StartResourceMonitors(monitorCtx)
 
 	app := fiber.New()
 	appInt := fiber.New()
@@ -57,6 +85,10 @@ func main() {
 	// healthcheck before any tracing/logging/metrics and on internal port
 	appInt.Use(healthcheck.New(healthcheck.Config{
 		LivenessProbe: func(c *fiber.Ctx) bool {
+			// deadlocked monitors won't recover on their own
+			if !services.MonitorsResponsive() {
+				return false
+			}
 			if config.GenteelRole == "agitator" {
 				// let's agitate
 				genteelAgitation, err := strconv.Atoi(config.GetEnv("GENTEEL_AGITATION", "0"))
@@ -74,11 +106,7 @@ func main() {
 		},
 		LivenessEndpoint: "/livez",
 		ReadinessProbe: func(c *fiber.Ctx) bool {
-			if services.GetGreaseBuildup() < 95 && services.GetInkDepletion() < 95 {
-				return true
-			} else {
-				return false
-			}
+			return !handlers.Draining(&draining) && !services.Saturated()
 		},
 		ReadinessEndpoint: "/readyz",
 	}))
@@ -93,53 +121,77 @@ func main() {
 	}
 
 	// we use both prometheus and OTEL
-	o11y.InitGenteelGauges(config.AppName, commonAttribs, services.GetGreaseBuildup, services.GetInkDepletion)
+	var resourceGauges []o11y.ResourceGauge
+	for _, resource := range services.Resources() {
+		resourceGauges = append(resourceGauges, o11y.ResourceGauge{
+			Name:        resource.Metric,
+			Description: resource.Description,
+			Level:       resource.Level,
+		})
+	}
+	if config.Dispatching() {
+		resourceGauges = append(resourceGauges, o11y.ResourceGauge{
+			Name:        "genteelbeacon_queue_depth",
+			Description: "The telegrams waiting in the queue to be written",
+			Level:       handlers.QueueDepth,
+		})
+	}
+	o11y.InitGenteelGauges(config.AppName, commonAttribs, resourceGauges)
+	o11y.InitDurationMetrics(config.AppName, commonAttribs)
+	o11y.InitOutcomeMetrics(config.AppName, commonAttribs)
+	o11y.InitStreamMetrics(config.AppName, commonAttribs)
+	// served as OpenMetrics if asked for, so the exemplars are included
 	prometheus := fiberprometheus.NewWithDefaultRegistry(config.AppName)
 	prometheus.RegisterAt(appInt, "/metrics")
-	app.Use(prometheus.Middleware)
+	// tracing first, so the request metrics find the trace for exemplars
 	app.Use(otelfiber.Middleware())
+	app.Use(prometheus.Middleware)
 
 	// configure sending OTEL if needed
 	// if it's not configured, everything just remains silent
-	otlphttpEndpoint, otlphttpOk := os.LookupEnv("OTLPHTTP_ENDPOINT")
-	otlphttpTracesEndpoint, otlphttpTracesOk := os.LookupEnv("OTLPHTTP_TRACES_ENDPOINT")
-	if otlphttpTracesOk {
-		tp, err := o11y.InitTracer(otlphttpTracesEndpoint, commonAttribs)
-		if err != nil {
-			slog.Error("Can't send traces")
-		}
-		defer func() {
-			_ = tp.Shutdown(context.Background())
-		}()
-		slog.InfoContext(context.Background(), "Sending traces to "+otlphttpTracesEndpoint)
-	} else if otlphttpOk {
-		tp, err := o11y.InitTracer(otlphttpEndpoint, commonAttribs)
+	// the providers are flushed on shutdown
+	var otelShutdowns []func(context.Context) error
+	traceExporter, err := o11y.OtlpExporterFromEnv(o11y.SignalTraces)
+	if err != nil {
+		log.Fatal("Invalid OTLP configuration: ", err)
+	}
+	metricExporter, err := o11y.OtlpExporterFromEnv(o11y.SignalMetrics)
+	if err != nil {
+		log.Fatal("Invalid OTLP configuration: ", err)
+	}
+	logExporter, err := o11y.OtlpExporterFromEnv(o11y.SignalLogs)
+	if err != nil {
+		log.Fatal("Invalid OTLP configuration: ", err)
+	}
+	if traceExporter != nil {
+		tp, err := o11y.InitTracer(*traceExporter, commonAttribs)
 		if err != nil {
 			slog.Error("Can't send traces")
+		} else {
+			otelShutdowns = append(otelShutdowns, tp.Shutdown)
+			slog.InfoContext(context.Background(), "Sending "+traceExporter.String())
 		}
-		defer func() {
-			_ = tp.Shutdown(context.Background())
-		}()
-		mp, err := o11y.InitMeter(otlphttpEndpoint, commonAttribs)
+	}
+	if metricExporter != nil {
+		mp, err := o11y.InitMeter(*metricExporter, commonAttribs)
 		if err != nil {
 			log.Fatal("Can't send metrics")
 		}
-		defer func() {
-			_ = mp.Shutdown(context.Background())
-		}()
-		lp, err := o11y.InitOtelLogger(otlphttpEndpoint, commonAttribs)
+		otelShutdowns = append(otelShutdowns, mp.Shutdown)
+		slog.InfoContext(context.Background(), "Sending "+metricExporter.String())
+	}
+	if logExporter != nil {
+		lp, err := o11y.InitOtelLogger(*logExporter, commonAttribs)
 		if err != nil {
 			log.Fatal("Can't send logs")
 		}
-		defer func() {
-			_ = lp.Shutdown(context.Background())
-		}()
-		slog.InfoContext(context.Background(), "Sending OTEL data to "+otlphttpEndpoint)
-	} else {
+		otelShutdowns = append(otelShutdowns, lp.Shutdown)
+		slog.InfoContext(context.Background(), "Sending "+logExporter.String())
+	}
+	if len(otelShutdowns) == 0 {
 		slog.InfoContext(context.Background(), "Not sending OTEL data")
 	}
 	// set up the logging with fanout to both stdout and (optionally) OTEL
-	_, jsonLogging := os.LookupEnv("JSONLOGGING")
 	o11y.CreateLogger(config.AppName, jsonLogging)
 	// always log traceID, spanID and requestID
 	loggerConfig := slogfiber.Config{
@@ -148,6 +200,7 @@ func main() {
 		WithRequestID:      true,
 		WithRequestHeader:  true,
 		WithResponseHeader: true,
+		Filters:            []slogfiber.Filter{handlers.Unstreamed},
 	}
 	app.Use(slogfiber.NewWithConfig(o11y.Logger, loggerConfig))
 	app.Use(recover.New())
@@ -155,23 +208,167 @@ func main() {
 	// start tracing now
 	// The tracer is accessed via otel.Tracer(config.AppName) throughout the code
 
+	shutdownTimeout, err := time.ParseDuration(config.GetEnv("GENTEEL_SHUTDOWN_TIMEOUT", "15s"))
+	if err != nil {
+		log.Fatal("Invalid GENTEEL_SHUTDOWN_TIMEOUT: ", err)
+	}
+	if shutdownTimeout <= 0 {
+		log.Fatal("GENTEEL_SHUTDOWN_TIMEOUT must be positive")
+	}
+	drainDelay, err := time.ParseDuration(config.GetEnv("GENTEEL_DRAIN_DELAY", "0s"))
+	if err != nil {
+		log.Fatal("Invalid GENTEEL_DRAIN_DELAY: ", err)
+	}
+	// the telemetry is flushed last, within the timeout
+	flushReserve := shutdownTimeout / flushShare
+
+	exitCode := 0
+	// the load generator or operator tell if they give up
+	var backgroundDone chan struct{}
+	backgroundErr := make(chan error, 1)
 	if config.GenteelRole == "gearsmith" {
-		gearsmith.RunGearsmith()
+		if err := gearsmith.RunGearsmith(ctx, shutdownTimeout-flushReserve); err != nil {
+			o11y.Logger.Error("Gearsmith failed: " + err.Error())
+			exitCode = 1
+		}
 	} else {
+		if config.GenteelRole == "loadgenerator" {
+			// generate load in the background, we still serve metrics and health
+			o11y.InitLoadMetrics(config.AppName, commonAttribs)
+			backgroundDone = make(chan struct{})
+			go func() {
+				defer close(backgroundDone)
+				if err := loadgenerator.RunLoadGenerator(ctx); err != nil {
+					backgroundErr <- fmt.Errorf("can't generate load: %w", err)
+				}
+			}()
+		}
+
+		if config.GenteelRole == "operator" {
+			// work the telegraph line in the background, we still serve metrics and health
+			backgroundDone = make(chan struct{})
+			go func() {
+				defer close(backgroundDone)
+				if err := operator.RunOperator(ctx); err != nil {
+					backgroundErr <- fmt.Errorf("can't operate the telegraph: %w", err)
+				}
+			}()
+		}
+
+		if config.GenteelRole == "agitator" {
+			// calamities may also be unleashed by flag
+			config.WatchCalamity(agitator.FollowFlag)
+		}
+
+		if config.Ledgering() {
+			// every telegram written is recorded
+			if err := services.OpenLedger(); err != nil {
+				log.Fatal("Can't open the ledger: ", err)
+			}
+		}
+
+		if config.Dispatching() {
+			// telegrams posted are written by the workers
+			if err := handlers.StartDispatch(); err != nil {
+				log.Fatal("Can't set up the queue: ", err)
+			}
+		}
 
 		handlers.RegisterRoutes(app)
+		handlers.RegisterInternalRoutes(appInt, &draining)
 		appPort := config.GetEnv("APP_PORT", "1333")
 		appAddr := config.GetEnv("APP_ADDR", "0.0.0.0")
 		appIntPort := config.GetEnv("INT_PORT", "1337")
 		appIntAddr := config.GetEnv("INT_ADDR", "127.0.0.1")
+		grpcPort := config.GetEnv("GRPC_PORT", "")
+		grpcAddr := config.GetEnv("GRPC_ADDR", "0.0.0.0")
 
-		var wg sync.WaitGroup
-		wg.Go(func() {
-			log.Fatal(appInt.Listen(appIntAddr + ":" + appIntPort))
+		listenErr := make(chan error, 3)
+		go func() {
+			listenErr <- appInt.Listen(appIntAddr + ":" + appIntPort)
+		}()
+		go func() {
+			listenErr <- app.Listen(appAddr + ":" + appPort)
+		}()
+		// the gRPC API is served next to the HTTP one only if asked for, it isn't authenticated
+		var grpcServer *grpc.Server
+		if grpcPort != "" {
+			grpcServer = handlers.NewGrpcServer(&draining)
+			go func() {
+				listener, err := net.Listen("tcp", grpcAddr+":"+grpcPort)
+				if err != nil {
+					listenErr <- err
+					return
+				}
+				o11y.Logger.Info("Serving gRPC on " + listener.Addr().String())
+				listenErr <- grpcServer.Serve(listener)
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			o11y.Logger.Info("Shutting down, draining requests 🚿")
+		case err := <-listenErr:
+			o11y.Logger.Error("Can't serve: " + err.Error())
+			exitCode = 1
+		case err := <-backgroundErr:
+			o11y.Logger.Error("Shutting down, " + err.Error())
+			exitCode = 1
+		}
+		stop()
+
+		// everything is stopped against one deadline, the share kept for the telemetry is never used up
+		stopCtx, cancelStop := context.WithTimeout(context.Background(), shutdownTimeout-flushReserve)
+		defer cancelStop()
+
+		// give the load balancers a moment to notice we are not ready anymore
+		draining.Store(true)
+		var stopping sync.WaitGroup
+		if grpcServer != nil {
+			// GOAWAY sends the clients elsewhere right away, calls still running at the deadline are cut off
+			stopping.Go(func() {
+				cutOff := context.AfterFunc(stopCtx, grpcServer.Stop)
+				grpcServer.GracefulStop()
+				cutOff()
+			})
+		}
+		// no more telegrams are posted, the queued ones are still written
+		stopping.Go(func() {
+			handlers.StopDispatch(stopCtx)
 		})
-		wg.Go(func() {
-			log.Fatal(app.Listen(appAddr + ":" + appPort))
+		select {
+		case <-time.After(drainDelay):
+		case <-stopCtx.Done():
+		}
+		// streams would run until the deadline, their clients reconnect elsewhere
+		handlers.CloseStreams()
+		// stop accepting and wait for the requests in flight
+		stopping.Go(func() {
+			if err := app.ShutdownWithContext(stopCtx); err != nil {
+				o11y.Logger.Error("Requests were cut off: " + err.Error())
+			}
 		})
-		wg.Wait()
+		stopping.Wait()
+		// nobody writes telegrams anymore
+		services.CloseLedger()
+		stopMonitors()
+		// the load generator's and operator's last requests still get their telemetry flushed
+		if backgroundDone != nil {
+			select {
+			case <-backgroundDone:
+			case <-stopCtx.Done():
+			}
+		}
+		_ = appInt.ShutdownWithContext(stopCtx)
+	}
+
+	// send whatever telemetry is left, a SIGKILL at the end of the grace period doesn't get to it otherwise
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushReserve)
+	defer cancelFlush()
+	for _, shutdown := range otelShutdowns {
+		if err := shutdown(flushCtx); err != nil {
+			slog.Error("Can't flush OTEL data", "err", err)
+		}
 	}
+	return exitCode
 }
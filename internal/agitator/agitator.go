@@ -0,0 +1,323 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package agitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/services"
+)
+
+// the failure modes that keep going in the background
+const (
+	ModeMemLeak       = "memleak"
+	ModeCPUBurn       = "cpuburn"
+	ModeGoroutineLeak = "goroutineleak"
+	ModeFDExhaustion  = "fdexhaustion"
+	ModeDeadlock      = "deadlock"
+	ModeOOM           = "oom"
+	// calms all of the above down again
+	ModeCalm = "calm"
+)
+
+// Calamity is a failure mode and how bad it gets
+type Calamity struct {
+	Mode string
+	// per second: megabytes for memleak, goroutines for goroutineleak, files for fdexhaustion
+	Rate float64
+	// cores to keep busy for cpuburn, all by default
+	Cores int
+	// how long the calamity lasts, until calmed down if 0
+	Duration time.Duration
+}
+
+// agitation is a running calamity
+type agitation struct {
+	stop context.CancelFunc
+}
+
+var (
+	lock sync.Mutex
+	// the running calamities, by mode
+	running = make(map[string]*agitation)
+	// what we hold on to, so it's not freed
+	leakedMemory   [][]byte
+	leakedFiles    []*os.File
+	leakedRelease  = make(chan struct{})
+	leakedRoutines int
+)
+
+// CalamityFromMap reads a calamity from a flag value or query, durations like "30s"
+func CalamityFromMap(settings map[string]string) (Calamity, error) {
+	calamity := Calamity{Mode: settings["mode"]}
+	var err error
+	if rate, ok := settings["rate"]; ok && rate != "" {
+		if calamity.Rate, err = strconv.ParseFloat(rate, 64); err != nil || calamity.Rate <= 0 {
+			return calamity, fmt.Errorf("invalid rate %q", rate)
+		}
+	}
+	if cores, ok := settings["cores"]; ok && cores != "" {
+		if calamity.Cores, err = strconv.Atoi(cores); err != nil || calamity.Cores <= 0 {
+			return calamity, fmt.Errorf("invalid cores %q", cores)
+		}
+	}
+	if duration, ok := settings["duration"]; ok && duration != "" {
+		if calamity.Duration, err = time.ParseDuration(duration); err != nil {
+			return calamity, fmt.Errorf("invalid duration %q", duration)
+		}
+	}
+	return calamity, nil
+}
+
+// Unleash starts the calamity in the background, replacing one of the same mode
+func Unleash(calamity Calamity) error {
+	var agitate func(ctx context.Context, calamity Calamity)
+	switch calamity.Mode {
+	case ModeCalm:
+		Calm()
+		return nil
+	case ModeMemLeak:
+		agitate = leakMemory
+	case ModeCPUBurn:
+		agitate = burnCPU
+	case ModeGoroutineLeak:
+		agitate = leakGoroutines
+	case ModeFDExhaustion:
+		agitate = exhaustFiles
+	case ModeDeadlock:
+		agitate = deadlockMonitors
+	case ModeOOM:
+		agitate = runOutOfMemory
+	default:
+		return errors.New("unknown calamity " + calamity.Mode)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if calamity.Duration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), calamity.Duration)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	current := &agitation{stop: cancel}
+	lock.Lock()
+	if previous, ok := running[calamity.Mode]; ok {
+		previous.stop()
+	}
+	running[calamity.Mode] = current
+	lock.Unlock()
+
+	o11y.Logger.Warn("Unleashing calamity 🌪️", "mode", calamity.Mode, "rate", calamity.Rate, "cores", calamity.Cores, "duration", calamity.Duration)
+	go func() {
+		agitate(ctx, calamity)
+		cancel()
+		// it's over, unless it was replaced in the meantime
+		lock.Lock()
+		if running[calamity.Mode] == current {
+			delete(running, calamity.Mode)
+		}
+		lock.Unlock()
+		o11y.Logger.Info("Calamity passed", "mode", calamity.Mode)
+	}()
+	return nil
+}
+
+// Calm stops all calamities and lets go of whatever they held on to
+func Calm() {
+	lock.Lock()
+	defer lock.Unlock()
+	for mode, agitation := range running {
+		agitation.stop()
+		delete(running, mode)
+	}
+	for _, file := range leakedFiles {
+		file.Close()
+	}
+	leakedFiles = nil
+	leakedMemory = nil
+	close(leakedRelease)
+	leakedRelease = make(chan struct{})
+	leakedRoutines = 0
+	services.UnjamMonitors()
+	runtime.GC()
+	o11y.Logger.Info("All calm again 🌤️")
+}
+
+// every tick, the calamity takes its next step
+func everyTick(ctx context.Context, interval time.Duration, step func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			step()
+		}
+	}
+}
+
+// allocate memory that is actually used, so it counts
+func allocate(megabytes float64) []byte {
+	chunk := make([]byte, int(megabytes*1024*1024))
+	for i := 0; i < len(chunk); i += 4096 {
+		chunk[i] = 1
+	}
+	return chunk
+}
+
+// leakMemory holds on to more memory every second
+func leakMemory(ctx context.Context, calamity Calamity) {
+	rate := calamity.Rate
+	if rate == 0 {
+		rate = 10
+	}
+	everyTick(ctx, 100*time.Millisecond, func() {
+		chunk := allocate(rate / 10)
+		lock.Lock()
+		leakedMemory = append(leakedMemory, chunk)
+		lock.Unlock()
+	})
+}
+
+// runOutOfMemory allocates as fast as it can, until the kernel or the runtime steps in
+func runOutOfMemory(ctx context.Context, calamity Calamity) {
+	for ctx.Err() == nil {
+		chunk := allocate(64)
+		lock.Lock()
+		leakedMemory = append(leakedMemory, chunk)
+		lock.Unlock()
+	}
+}
+
+// burnCPU keeps the cores busy with nothing
+func burnCPU(ctx context.Context, calamity Calamity) {
+	cores := calamity.Cores
+	if cores == 0 {
+		cores = runtime.NumCPU()
+	}
+	for range cores {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					for i := 0; i < 1_000_000; i++ {
+						_ = i * i
+					}
+				}
+			}
+		}()
+	}
+	<-ctx.Done()
+}
+
+// leakGoroutines starts goroutines that wait forever, or until calmed down
+func leakGoroutines(ctx context.Context, calamity Calamity) {
+	rate := calamity.Rate
+	if rate == 0 {
+		rate = 100
+	}
+	everyTick(ctx, 100*time.Millisecond, func() {
+		lock.Lock()
+		release := leakedRelease
+		for range max(int(rate/10), 1) {
+			go func() {
+				<-release
+			}()
+			leakedRoutines++
+		}
+		lock.Unlock()
+	})
+}
+
+// exhaustFiles opens files and never closes them, until there are none left to open
+func exhaustFiles(ctx context.Context, calamity Calamity) {
+	rate := calamity.Rate
+	if rate == 0 {
+		rate = 100
+	}
+	exhausted := false
+	everyTick(ctx, 100*time.Millisecond, func() {
+		for range max(int(rate/10), 1) {
+			file, err := os.Open(os.DevNull)
+			if err != nil {
+				if !exhausted {
+					o11y.Logger.Error("Out of file descriptors: " + err.Error())
+					exhausted = true
+				}
+				return
+			}
+			lock.Lock()
+			leakedFiles = append(leakedFiles, file)
+			lock.Unlock()
+		}
+	})
+}
+
+// deadlockMonitors jams the resource monitors, so requests waiting for their resources hang
+func deadlockMonitors(ctx context.Context, calamity Calamity) {
+	services.JamMonitors()
+	<-ctx.Done()
+	services.UnjamMonitors()
+}
+
+// Running tells which calamities are going on and what they hold on to
+func Running() map[string]any {
+	lock.Lock()
+	defer lock.Unlock()
+	modes := make([]string, 0, len(running))
+	for mode := range running {
+		modes = append(modes, mode)
+	}
+	var leakedBytes int
+	for _, chunk := range leakedMemory {
+		leakedBytes += len(chunk)
+	}
+	return map[string]any{
+		"modes":          modes,
+		"leakedBytes":    leakedBytes,
+		"leakedFiles":    len(leakedFiles),
+		"leakedRoutines": leakedRoutines,
+	}
+}
+
+// the calamity the flag asked for last, to only act on changes
+var flagCalamity Calamity
+
+// FollowFlag unleashes the calamity the flag asks for, an empty flag calms down what it started
+func FollowFlag(settings map[string]string) {
+	calamity, err := CalamityFromMap(settings)
+	if err != nil {
+		o11y.Logger.Error("Invalid calamity flag: " + err.Error())
+		return
+	}
+	lock.Lock()
+	previous := flagCalamity
+	flagCalamity = calamity
+	lock.Unlock()
+	if calamity == previous {
+		return
+	}
+	if calamity.Mode == "" {
+		if previous.Mode != "" {
+			Calm()
+		}
+		return
+	}
+	if err := Unleash(calamity); err != nil {
+		o11y.Logger.Error("Invalid calamity flag: " + err.Error())
+	}
+}
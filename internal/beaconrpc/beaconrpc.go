@@ -0,0 +1,9 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package beaconrpc is the gRPC API, generated from beacon.proto.
+package beaconrpc
+
+//go:generate sh -c "protoc --plugin=protoc-gen-go=$(go tool -n protoc-gen-go) --plugin=protoc-gen-go-grpc=$(go tool -n protoc-gen-go-grpc) --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative beacon.proto"
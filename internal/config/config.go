@@ -6,14 +6,20 @@
 package config
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
-	"sync/atomic"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
 	flagd "github.com/open-feature/go-sdk-contrib/providers/flagd/pkg"
 	"github.com/open-feature/go-sdk/openfeature"
+	"sigs.k8s.io/yaml"
 )
 
 var (
@@ -21,18 +27,20 @@ var (
 	GenteelRole  string
 	NodeName     string
 	BuildVersion string = "0.0.0" // should be overridden at compile time with -ldflags
-	chaosMode    atomic.Bool
-	chaosGates   = map[string]float64{
-		"penDropChance":    0.01,
-		"breakChance":      0.02,
-		"indisposedChance": 0.04,
-	}
-)
-
-// Service behavior constants
-const (
-	// Threshold to start tripping ink/grease errors, integer percentage
-	TripThreshold = 90
+	// upstream beacons the relay forwards to
+	RelayUpstreams []string
+	RelayTimeout   time.Duration
+	RelayMaxHops   int
+	// the call graph to emulate, nil if none is configured
+	Topology *types.Topology
+	// evaluate chaos flags per request, with these (lowercase) request headers
+	ChaosTargeting        bool
+	ChaosTargetingTimeout time.Duration
+	ChaosHeaders          []string
+	// the cadence of streamed telegrams
+	StreamInterval time.Duration
+	// how long the telegraph holds back a message while the ink is dry
+	TelegraphStall time.Duration
 )
 
 // GetEnv gets an environment variable with a default value
@@ -56,56 +64,200 @@ func Initialize() error {
 		NodeName = "unknown_host"
 	}
 
-	// Create a flagd provider pointing to flagd server
+	// the relay needs to know where to forward to
+	if GenteelRole == "relay" {
+		for _, upstream := range strings.Split(GetEnv("GENTEEL_RELAY_UPSTREAMS", ""), ",") {
+			upstream = strings.TrimSuffix(strings.TrimSpace(upstream), "/")
+			if upstream != "" {
+				RelayUpstreams = append(RelayUpstreams, upstream)
+			}
+		}
+		if len(RelayUpstreams) == 0 {
+			return errors.New("relay role requires GENTEEL_RELAY_UPSTREAMS")
+		}
+		RelayTimeout, err = time.ParseDuration(GetEnv("GENTEEL_RELAY_TIMEOUT", "10s"))
+		if err != nil {
+			return err
+		}
+		RelayMaxHops, err = strconv.Atoi(GetEnv("GENTEEL_RELAY_MAX_HOPS", "10"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// how the courier copes with the clock
+	if err := initCourier(); err != nil {
+		slog.Error("Error configuring the courier", "err", err)
+		return err
+	}
+
+	// how telegrams are dispatched asynchronously
+	if err := initQueue(); err != nil {
+		slog.Error("Error configuring the queue", "err", err)
+		return err
+	}
+
+	// where the telegrams sent are recorded
+	if err := initLedger(); err != nil {
+		slog.Error("Error configuring the ledger", "err", err)
+		return err
+	}
+
+	// declare ink, grease and whatever else is consumed
+	if err := initResources(); err != nil {
+		slog.Error("Error configuring resources", "err", err)
+		return err
+	}
+
+	// load the call graph to emulate
+	topologyFile := GetEnv("GENTEEL_TOPOLOGY", "")
+	if topologyFile != "" {
+		Topology, err = loadTopology(topologyFile)
+		if err != nil {
+			slog.Error("Error loading topology", "err", err)
+			return err
+		}
+	}
+
+	// targeting chaos needs the request's context for every evaluation
+	ChaosTargeting, err = strconv.ParseBool(GetEnv("GENTEEL_CHAOS_TARGETING", "false"))
+	if err != nil {
+		return err
+	}
+	ChaosTargetingTimeout, err = time.ParseDuration(GetEnv("GENTEEL_CHAOS_TARGETING_TIMEOUT", "100ms"))
+	if err != nil {
+		return err
+	}
+	for header := range strings.SplitSeq(GetEnv("GENTEEL_CHAOS_HEADERS", "X-Genteel-Tenant"), ",") {
+		if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+			ChaosHeaders = append(ChaosHeaders, header)
+		}
+	}
+
+	StreamInterval, err = time.ParseDuration(GetEnv("GENTEEL_STREAM_INTERVAL", "2s"))
+	if err != nil {
+		return err
+	}
+	if StreamInterval <= 0 {
+		return errors.New("GENTEEL_STREAM_INTERVAL must be positive")
+	}
+	TelegraphStall, err = time.ParseDuration(GetEnv("GENTEEL_TELEGRAPH_STALL", "10s"))
+	if err != nil {
+		return err
+	}
+
+	// the sampling policy from the environment, the samplingPolicy flag may override it
+	if err := o11y.InitSampling(); err != nil {
+		slog.Error("Error configuring sampling", "err", err)
+		return err
+	}
+
+	// Create a flagd provider, asking a flagd server for every flag (rpc),
+	// syncing the flags to evaluate locally (in-process) or reading them from a file
 	flagdHost := GetEnv("FLAGD_HOST", "")
-	if flagdHost != "" {
-		provider, err := flagd.NewProvider(
-			flagd.WithHost(flagdHost), // flagd server address
-			flagd.WithPort(8013),      // flagd port (default 8013)
-		)
+	flagdSocketPath := GetEnv("FLAGD_SOCKET_PATH", "")
+	flagdFlagFile := GetEnv("FLAGD_OFFLINE_FLAG_SOURCE_PATH", "")
+	if flagdHost != "" || flagdSocketPath != "" || flagdFlagFile != "" {
+		providerOptions, err := flagdOptions(flagdHost, flagdSocketPath, flagdFlagFile)
+		if err != nil {
+			slog.Error("Error configuring flagd provider", "err", err)
+			return err
+		}
+		provider, err := flagd.NewProvider(providerOptions...)
 		if err != nil {
 			slog.Error("Error creating flagd provider", "err", err)
 			return err
 		}
+		// keep the flags cached, the provider tells us when they change
+		watchFlags()
 		openfeature.SetProvider(provider)
-		startsettingChaosMode()
 	}
 
 	return nil
 }
 
-func startsettingChaosMode() {
-	go func() {
-		for {
-			time.Sleep(5 * time.Second)
-			client := openfeature.NewClient(AppName)
-			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-
-			chaosModeVal, err := client.BooleanValue(ctx, "chaosMode", false, openfeature.EvaluationContext{})
-			if err != nil {
-				slog.Error("Error getting chaos mode value", "err", err)
-				chaosMode.Store(false)
-			} else {
-				chaosMode.Store(chaosModeVal)
-			}
-			cancel()
-		}
-	}()
-}
+// flagdOptions translates our environment into flagd provider options
+func flagdOptions(flagdHost string, flagdSocketPath string, flagdFlagFile string) ([]flagd.ProviderOption, error) {
+	var providerOptions []flagd.ProviderOption
 
-func GetChaosChance(gate string) float64 {
-	if chaosMode.Load() {
-		client := openfeature.NewClient(AppName)
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		defer cancel()
+	// a flag file alone means file mode
+	defaultResolver := "rpc"
+	if flagdFlagFile != "" {
+		defaultResolver = "file"
+	}
+	switch resolver := GetEnv("FLAGD_RESOLVER", defaultResolver); resolver {
+	case "rpc":
+		// every request would wait for a round trip to flagd
+		if ChaosTargeting {
+			return nil, errors.New("GENTEEL_CHAOS_TARGETING requires the in-process or file resolver")
+		}
+		providerOptions = append(providerOptions, flagd.WithRPCResolver())
+	case "in-process":
+		providerOptions = append(providerOptions, flagd.WithInProcessResolver())
+	case "file":
+		if flagdFlagFile == "" {
+			return nil, errors.New("file resolver requires FLAGD_OFFLINE_FLAG_SOURCE_PATH")
+		}
+		providerOptions = append(providerOptions, flagd.WithFileResolver(), flagd.WithOfflineFilePath(flagdFlagFile))
+	default:
+		return nil, fmt.Errorf("unknown flagd resolver %q", resolver)
+	}
 
-		penDropChanceVal, err := client.FloatValue(ctx, gate, chaosGates[gate], openfeature.EvaluationContext{})
+	if flagdHost != "" {
+		providerOptions = append(providerOptions, flagd.WithHost(flagdHost))
+	}
+	// without a port, flagd's defaults apply: 8013 for rpc and 8015 for in-process
+	if flagdPort := GetEnv("FLAGD_PORT", ""); flagdPort != "" {
+		port, err := strconv.ParseUint(flagdPort, 10, 16)
 		if err != nil {
-			slog.Error("Error getting pen drop chance value", "err", err)
-			return chaosGates[gate] // return default
+			return nil, fmt.Errorf("invalid FLAGD_PORT: %w", err)
+		}
+		providerOptions = append(providerOptions, flagd.WithPort(uint16(port)))
+	}
+	if flagdSocketPath != "" {
+		providerOptions = append(providerOptions, flagd.WithSocketPath(flagdSocketPath))
+	}
+	flagdTLS, err := strconv.ParseBool(GetEnv("FLAGD_TLS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FLAGD_TLS: %w", err)
+	}
+	if flagdTLS {
+		// without a certificate, the system's are used
+		providerOptions = append(providerOptions, flagd.WithTLS(GetEnv("FLAGD_SERVER_CERT_PATH", "")))
+	}
+
+	return providerOptions, nil
+}
+
+// loadTopology reads and checks the topology file, YAML or JSON
+func loadTopology(topologyFile string) (*types.Topology, error) {
+	topologyData, err := os.ReadFile(topologyFile)
+	if err != nil {
+		return nil, err
+	}
+	var topology types.Topology
+	if err := yaml.UnmarshalStrict(topologyData, &topology); err != nil {
+		return nil, err
+	}
+	for name, endpoint := range topology.Endpoints {
+		if endpoint.Mode != "" && endpoint.Mode != "sequential" && endpoint.Mode != "parallel" {
+			return nil, fmt.Errorf("endpoint %s: unknown mode %q", name, endpoint.Mode)
+		}
+		for i, edge := range endpoint.Calls {
+			if edge.Beacon == "" || edge.Endpoint == "" {
+				return nil, fmt.Errorf("endpoint %s: call %d needs beacon and endpoint", name, i)
+			}
+			if edge.ErrorChance < 0 || edge.ErrorChance > 1 {
+				return nil, fmt.Errorf("endpoint %s: call %d errorChance must be between 0 and 1", name, i)
+			}
+			if edge.Latency != "" {
+				endpoint.Calls[i].Delay, err = time.ParseDuration(edge.Latency)
+				if err != nil {
+					return nil, fmt.Errorf("endpoint %s: call %d: %w", name, i, err)
+				}
+			}
+			endpoint.Calls[i].Beacon = strings.TrimSuffix(edge.Beacon, "/")
 		}
-		return penDropChanceVal
-	} else {
-		return 0.0
 	}
+	return &topology, nil
 }
@@ -0,0 +1,97 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the ways to pick the clocks to ask
+const (
+	ClockRoundRobin = "roundrobin"
+	ClockRandom     = "random"
+	ClockHedged     = "hedged"
+	ClockQuorum     = "quorum"
+)
+
+// CourierPolicy says how the courier copes with an unreliable clock
+type CourierPolicy struct {
+	Clocks           []string      // the addresses of the clocks, none to go without
+	Strategy         string        // how to pick among the clocks
+	HedgeDelay       time.Duration // how long to wait for a clock before also asking the next, when hedging
+	Quorum           int           // how many clocks need to answer, for a quorum
+	MaxSkew          time.Duration // how far a clock may be off the median before it is reported, for a quorum
+	Timeout          time.Duration // for a single call to the clock
+	Retries          int           // further attempts after the first one fails
+	BackoffInitial   time.Duration // wait before the first retry, doubled for every further one
+	BackoffMax       time.Duration // the longest wait between retries
+	BreakerThreshold int           // consecutive failures opening the circuit breaker, 0 for none
+	BreakerCooldown  time.Duration // how long the breaker stays open before a probe is let through
+	Fallback         bool          // whether to go with the local date when the clock can't be reached
+}
+
+// Courier is the policy read from GENTEEL_COURIER_* environment variables
+var Courier CourierPolicy
+
+// initCourier reads the courier's policy
+func initCourier() error {
+	var err error
+	for clock := range strings.SplitSeq(GetEnv("GENTEEL_CLOCK", ""), ",") {
+		if clock = strings.TrimSuffix(strings.TrimSpace(clock), "/"); clock != "" {
+			Courier.Clocks = append(Courier.Clocks, clock)
+		}
+	}
+	switch Courier.Strategy = GetEnv("GENTEEL_CLOCK_STRATEGY", ClockRoundRobin); Courier.Strategy {
+	case ClockRoundRobin, ClockRandom, ClockHedged, ClockQuorum:
+	default:
+		return fmt.Errorf("unknown GENTEEL_CLOCK_STRATEGY %q", Courier.Strategy)
+	}
+	// a majority by default
+	if Courier.Quorum, err = strconv.Atoi(GetEnv("GENTEEL_CLOCK_QUORUM", strconv.Itoa(len(Courier.Clocks)/2+1))); err != nil ||
+		Courier.Quorum < 1 || (len(Courier.Clocks) > 0 && Courier.Quorum > len(Courier.Clocks)) {
+		return fmt.Errorf("invalid GENTEEL_CLOCK_QUORUM, must be between 1 and the number of clocks")
+	}
+	durations := []struct {
+		name         string
+		defaultValue string
+		target       *time.Duration
+		positive     bool // zero is no good either
+	}{
+		{"GENTEEL_CLOCK_HEDGE_DELAY", "100ms", &Courier.HedgeDelay, false},
+		{"GENTEEL_CLOCK_MAX_SKEW", "500ms", &Courier.MaxSkew, false},
+		{"GENTEEL_COURIER_TIMEOUT", "2s", &Courier.Timeout, true},
+		{"GENTEEL_COURIER_BACKOFF", "100ms", &Courier.BackoffInitial, false},
+		{"GENTEEL_COURIER_BACKOFF_MAX", "2s", &Courier.BackoffMax, false},
+		{"GENTEEL_COURIER_BREAKER_COOLDOWN", "10s", &Courier.BreakerCooldown, true},
+	}
+	for _, duration := range durations {
+		if *duration.target, err = time.ParseDuration(GetEnv(duration.name, duration.defaultValue)); err != nil {
+			return fmt.Errorf("invalid %s: %w", duration.name, err)
+		}
+		if *duration.target < 0 {
+			return fmt.Errorf("invalid %s, must not be negative", duration.name)
+		}
+		if duration.positive && *duration.target == 0 {
+			return fmt.Errorf("invalid %s, must be positive", duration.name)
+		}
+	}
+	if Courier.BackoffMax < Courier.BackoffInitial {
+		return fmt.Errorf("invalid GENTEEL_COURIER_BACKOFF_MAX, must not be below GENTEEL_COURIER_BACKOFF")
+	}
+	if Courier.Retries, err = strconv.Atoi(GetEnv("GENTEEL_COURIER_RETRIES", "2")); err != nil || Courier.Retries < 0 {
+		return fmt.Errorf("invalid GENTEEL_COURIER_RETRIES")
+	}
+	if Courier.BreakerThreshold, err = strconv.Atoi(GetEnv("GENTEEL_COURIER_BREAKER_THRESHOLD", "5")); err != nil || Courier.BreakerThreshold < 0 {
+		return fmt.Errorf("invalid GENTEEL_COURIER_BREAKER_THRESHOLD")
+	}
+	if Courier.Fallback, err = strconv.ParseBool(GetEnv("GENTEEL_COURIER_FALLBACK", "false")); err != nil {
+		return fmt.Errorf("invalid GENTEEL_COURIER_FALLBACK: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// the network fault gates
+var faultGates = []string{"courierFault"}
+
+// FaultModel describes a network fault to inject into outgoing calls
+type FaultModel struct {
+	Chance float64       // chance of the fault for a call
+	Fault  string        // drop, status, truncate, corrupt, dnsdelay or timeout
+	Status int           // the synthetic status for status
+	Delay  time.Duration // how long the lookup takes for dnsdelay, or until the timeout
+}
+
+// parseFaultModel reads a fault model from the settings of an object flag
+func parseFaultModel(settings map[string]any) (FaultModel, error) {
+	model := FaultModel{Chance: 1, Status: http.StatusServiceUnavailable}
+	var err error
+	for key, value := range settings {
+		stringValue := fmt.Sprint(value)
+		switch key {
+		case "chance":
+			model.Chance, err = strconv.ParseFloat(stringValue, 64)
+		case "fault":
+			model.Fault = stringValue
+		case "status":
+			model.Status, err = strconv.Atoi(stringValue)
+		case "delay":
+			model.Delay, err = time.ParseDuration(stringValue)
+		default:
+			err = fmt.Errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return model, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	switch model.Fault {
+	case "drop", "truncate", "corrupt":
+	case "status":
+		if model.Status < 100 || model.Status > 599 {
+			return model, fmt.Errorf("invalid status %d", model.Status)
+		}
+	case "dnsdelay":
+		if model.Delay == 0 {
+			model.Delay = 2 * time.Second
+		}
+	case "timeout":
+		if model.Delay == 0 {
+			model.Delay = 30 * time.Second
+		}
+	default:
+		return model, fmt.Errorf("unknown fault %q", model.Fault)
+	}
+	return model, nil
+}
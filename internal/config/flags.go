@@ -0,0 +1,340 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync/atomic"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+var (
+	chaosMode  atomic.Bool
+	chaosGates = map[string]float64{
+		"penDropChance":    0.01,
+		"breakChance":      0.02,
+		"indisposedChance": 0.04,
+	}
+	// the latest flag values, so requests never wait for flagd
+	chaosCache   atomic.Pointer[map[string]float64]
+	latencyCache atomic.Pointer[map[string]LatencyModel]
+	faultCache   atomic.Pointer[map[string]FaultModel]
+	// the log levels last set by the logLevels flag
+	flagLogLevels atomic.Pointer[map[string]string]
+	// told about the calamity flag, an empty map means calm
+	calamityHandler atomic.Pointer[func(settings map[string]string)]
+	// event handlers, registered by pointer
+	flagsChanged = func(details openfeature.EventDetails) {
+		slog.Debug("Flags changed", "provider", details.ProviderName, "flags", details.FlagChanges)
+		refreshFlags()
+	}
+	flagsError = func(details openfeature.EventDetails) {
+		// we keep the last known values
+		slog.Error("Flag provider error", "provider", details.ProviderName, "message", details.Message)
+	}
+)
+
+func init() {
+	chaosCache.Store(&chaosGates)
+	latencyCache.Store(&map[string]LatencyModel{})
+	faultCache.Store(&map[string]FaultModel{})
+	flagLogLevels.Store(&map[string]string{})
+}
+
+// watchFlags refreshes the cached flags whenever the provider becomes ready or reports changes
+func watchFlags() {
+	openfeature.AddHandler(openfeature.ProviderReady, &flagsChanged)
+	openfeature.AddHandler(openfeature.ProviderConfigChange, &flagsChanged)
+	openfeature.AddHandler(openfeature.ProviderError, &flagsError)
+}
+
+// refreshFlags evaluates all flags we care about and caches them
+func refreshFlags() {
+	client := openfeature.NewClient(AppName)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	chaosModeVal, err := client.BooleanValue(ctx, "chaosMode", false, openfeature.EvaluationContext{})
+	if err != nil {
+		slog.Error("Error getting chaos mode value", "err", err)
+		chaosMode.Store(false)
+	} else {
+		chaosMode.Store(chaosModeVal)
+	}
+
+	gates := maps.Clone(chaosGates)
+	for gate, defaultChance := range chaosGates {
+		gates[gate], err = client.FloatValue(ctx, gate, defaultChance, openfeature.EvaluationContext{})
+		if err != nil {
+			slog.Error("Error getting chaos gate value", "gate", gate, "err", err)
+		}
+	}
+	chaosCache.Store(&gates)
+
+	latencies := make(map[string]LatencyModel)
+	for _, gate := range LatencyGates() {
+		if model, ok := evaluateLatencyModel(ctx, client, gate); ok {
+			latencies[gate] = model
+		}
+	}
+	latencyCache.Store(&latencies)
+
+	faults := make(map[string]FaultModel)
+	for _, gate := range faultGates {
+		if model, ok := evaluateFaultModel(ctx, client, gate); ok {
+			faults[gate] = model
+		}
+	}
+	faultCache.Store(&faults)
+
+	refreshResourceModels(ctx, client)
+	refreshSamplingPolicy(ctx, client)
+	refreshLogLevels(ctx, client)
+	refreshCalamity(ctx, client)
+}
+
+// WatchCalamity has the handler told about the calamity flag, now and whenever it changes
+func WatchCalamity(handler func(settings map[string]string)) {
+	calamityHandler.Store(&handler)
+	// the provider may have been ready before
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	refreshCalamity(ctx, openfeature.NewClient(AppName))
+}
+
+// refreshCalamity passes the calamity flag on to the agitator, if there is one
+func refreshCalamity(ctx context.Context, client *openfeature.Client) {
+	handler := calamityHandler.Load()
+	if handler == nil {
+		return
+	}
+	settings, err := client.ObjectValue(ctx, "calamity", map[string]any{}, openfeature.EvaluationContext{})
+	if err != nil {
+		settings = map[string]any{}
+	}
+	settingsMap, ok := settings.(map[string]any)
+	if !ok {
+		slog.Error("Calamity flag is not an object")
+		return
+	}
+	calamity := make(map[string]string)
+	for key, value := range settingsMap {
+		calamity[key] = fmt.Sprint(value)
+	}
+	(*handler)(calamity)
+}
+
+// refreshLogLevels applies the logLevels flag, mapping components (or "default") to levels
+func refreshLogLevels(ctx context.Context, client *openfeature.Client) {
+	settings, err := client.ObjectValue(ctx, "logLevels", map[string]any{}, openfeature.EvaluationContext{})
+	if err != nil {
+		settings = map[string]any{}
+	}
+	settingsMap, ok := settings.(map[string]any)
+	if !ok {
+		slog.Error("Log levels flag is not an object")
+		return
+	}
+	levels := make(map[string]string)
+	for component, level := range settingsMap {
+		levels[component], _ = level.(string)
+	}
+	// what the flag no longer mentions goes back to the environment's level
+	for component := range *flagLogLevels.Swap(&levels) {
+		if _, ok := levels[component]; !ok {
+			_ = o11y.SetLogLevel(component, "")
+		}
+	}
+	for component, level := range levels {
+		if err := o11y.SetLogLevel(component, level); err != nil {
+			slog.Error("Error applying log level", "component", component, "err", err)
+		}
+	}
+}
+
+// refreshSamplingPolicy applies the samplingPolicy flag, an empty object means the policy from the environment
+func refreshSamplingPolicy(ctx context.Context, client *openfeature.Client) {
+	settings, err := client.ObjectValue(ctx, "samplingPolicy", map[string]any{}, openfeature.EvaluationContext{})
+	if err != nil {
+		settings = map[string]any{}
+	}
+	settingsMap, ok := settings.(map[string]any)
+	if !ok || len(settingsMap) == 0 {
+		o11y.ResetSamplingPolicy()
+		return
+	}
+	policy, err := o11y.SamplingPolicyFromMap(settingsMap)
+	if err == nil {
+		err = o11y.SetSamplingPolicy(policy)
+	}
+	if err != nil {
+		slog.Error("Error applying sampling policy", "err", err)
+	}
+}
+
+// refreshResourceModels picks up resource models from flagd, e.g. the inkModel flag
+func refreshResourceModels(ctx context.Context, client *openfeature.Client) {
+	for name := range resourceModels {
+		settings, err := client.ObjectValue(ctx, name+"Model", map[string]any{}, openfeature.EvaluationContext{})
+		if err != nil {
+			settings = map[string]any{}
+		}
+		settingsMap, ok := settings.(map[string]any)
+		if !ok {
+			slog.Error("Resource model flag is not an object", "resource", name)
+			continue
+		}
+		if err := overrideResourceModel(name, settingsMap); err != nil {
+			slog.Error("Error applying resource model", "resource", name, "err", err)
+		}
+	}
+}
+
+// GetChaosChance returns the chance for the gate, 0 unless in chaos mode.
+// A local override beats the flags. With targeting, the flags are evaluated with the request's evaluation context,
+// otherwise the cached values are used.
+func GetChaosChance(ctx context.Context, gate string) float64 {
+	cachedChance := (*chaosCache.Load())[gate]
+	if override := chaosOverride.Load(); override != nil {
+		if !override.ChaosMode {
+			return 0.0
+		}
+		if chance, ok := override.Gates[gate]; ok {
+			return chance
+		}
+		return cachedChance
+	}
+	if !ChaosTargeting {
+		if chaosMode.Load() {
+			return cachedChance
+		} else {
+			return 0.0
+		}
+	}
+
+	client := openfeature.NewClient(AppName)
+	ctx, cancel := context.WithTimeout(ctx, ChaosTargetingTimeout)
+	defer cancel()
+	if !targetedChaosMode(ctx, client) {
+		return 0.0
+	}
+	chance, err := client.FloatValue(ctx, gate, cachedChance, openfeature.EvaluationContext{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting targeted chaos gate value", "gate", gate, "err", err)
+	}
+	return chance
+}
+
+// GetLatencyModel returns the model for the latency gate, false if there is no latency to add
+func GetLatencyModel(ctx context.Context, gate string) (LatencyModel, bool) {
+	cachedModel, cached := (*latencyCache.Load())[gate]
+	if override := chaosOverride.Load(); override != nil {
+		return cachedModel, cached && override.ChaosMode
+	}
+	if !ChaosTargeting {
+		return cachedModel, cached && chaosMode.Load()
+	}
+
+	client := openfeature.NewClient(AppName)
+	ctx, cancel := context.WithTimeout(ctx, ChaosTargetingTimeout)
+	defer cancel()
+	if !targetedChaosMode(ctx, client) {
+		return LatencyModel{}, false
+	}
+	return evaluateLatencyModel(ctx, client, gate)
+}
+
+// GetFaultModel returns the model for the fault gate, false if there is no fault to inject
+func GetFaultModel(ctx context.Context, gate string) (FaultModel, bool) {
+	cachedModel, cached := (*faultCache.Load())[gate]
+	if override := chaosOverride.Load(); override != nil {
+		return cachedModel, cached && override.ChaosMode
+	}
+	if !ChaosTargeting {
+		return cachedModel, cached && chaosMode.Load()
+	}
+
+	client := openfeature.NewClient(AppName)
+	ctx, cancel := context.WithTimeout(ctx, ChaosTargetingTimeout)
+	defer cancel()
+	if !targetedChaosMode(ctx, client) {
+		return FaultModel{}, false
+	}
+	return evaluateFaultModel(ctx, client, gate)
+}
+
+// targetedChaosMode evaluates the chaos mode for the request,
+// the transaction context of ctx is merged in by the client
+func targetedChaosMode(ctx context.Context, client *openfeature.Client) bool {
+	chaosModeVal, err := client.BooleanValue(ctx, "chaosMode", chaosMode.Load(), openfeature.EvaluationContext{})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting targeted chaos mode value", "err", err)
+	}
+	return chaosModeVal
+}
+
+// evaluateLatencyModel reads the latency gate's object flag, an empty object means no latency
+func evaluateLatencyModel(ctx context.Context, client *openfeature.Client, gate string) (LatencyModel, bool) {
+	settings, err := client.ObjectValue(ctx, gate, map[string]any{}, openfeature.EvaluationContext{})
+	if err != nil {
+		return LatencyModel{}, false
+	}
+	settingsMap, ok := settings.(map[string]any)
+	if !ok || len(settingsMap) == 0 {
+		return LatencyModel{}, false
+	}
+	model, err := parseLatencyModel(settingsMap)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error parsing latency gate", "gate", gate, "err", err)
+		return LatencyModel{}, false
+	}
+	return model, true
+}
+
+// evaluateFaultModel reads the fault gate's object flag, an empty object means no fault
+func evaluateFaultModel(ctx context.Context, client *openfeature.Client, gate string) (FaultModel, bool) {
+	settings, err := client.ObjectValue(ctx, gate, map[string]any{}, openfeature.EvaluationContext{})
+	if err != nil {
+		return FaultModel{}, false
+	}
+	settingsMap, ok := settings.(map[string]any)
+	if !ok || len(settingsMap) == 0 {
+		return FaultModel{}, false
+	}
+	model, err := parseFaultModel(settingsMap)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error parsing fault gate", "gate", gate, "err", err)
+		return FaultModel{}, false
+	}
+	return model, true
+}
+
+// ChaosEvaluationContext describes a request for targeting chaos flags
+func ChaosEvaluationContext(requestID string, path string, traceID string, headers map[string]string) openfeature.EvaluationContext {
+	attributes := map[string]any{
+		"role":     GenteelRole,
+		"appName":  AppName,
+		"hostname": NodeName,
+		"path":     path,
+	}
+	if traceID != "" {
+		attributes["traceId"] = traceID
+	}
+	for _, header := range ChaosHeaders {
+		if value, ok := headers[header]; ok {
+			attributes[header] = value
+		}
+	}
+	// the request ID is random, good for fractional evaluation
+	return openfeature.NewEvaluationContext(requestID, attributes)
+}
@@ -0,0 +1,116 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// the latency gates of the services and the ledger, resources add their own
+var latencyGates = []string{"clerkLatency", "scribeLatency", "courierLatency", "ledgerLatency"}
+
+// LatencyGates returns the flags of all latency gates
+func LatencyGates() []string {
+	gates := slices.Clone(latencyGates)
+	for _, spec := range resourceSpecs {
+		gates = append(gates, spec.LatencyGate)
+	}
+	return gates
+}
+
+// LatencyModel describes how much delay a latency gate adds
+type LatencyModel struct {
+	Chance       float64       // chance of any delay at all
+	Distribution string        // constant, uniform, normal, lognormal or pareto
+	Delay        time.Duration // constant
+	Min          time.Duration // uniform
+	Max          time.Duration // upper bound for uniform, cap for all others
+	Mean         time.Duration // normal
+	StdDev       time.Duration // normal
+	Median       time.Duration // lognormal
+	Sigma        float64       // lognormal
+	Scale        time.Duration // pareto, the minimum delay
+	Alpha        float64       // pareto, the smaller the heavier the tail
+}
+
+// Sample draws a delay from the distribution
+func (m LatencyModel) Sample() time.Duration {
+	var delay float64
+	switch m.Distribution {
+	case "uniform":
+		delay = float64(m.Min) + rand.Float64()*float64(m.Max-m.Min)
+	case "normal":
+		delay = float64(m.Mean) + rand.NormFloat64()*float64(m.StdDev)
+	case "lognormal":
+		delay = float64(m.Median) * math.Exp(m.Sigma*rand.NormFloat64())
+	case "pareto":
+		// 1-Float64 is in (0,1], so we never divide by zero
+		delay = float64(m.Scale) / math.Pow(1-rand.Float64(), 1/m.Alpha)
+	default:
+		delay = float64(m.Delay)
+	}
+	if m.Max > 0 {
+		delay = min(delay, float64(m.Max))
+	}
+	return time.Duration(max(delay, 0))
+}
+
+// parseLatencyModel reads a latency model from the settings of an object flag
+func parseLatencyModel(settings map[string]any) (LatencyModel, error) {
+	model := LatencyModel{Chance: 1, Distribution: "constant"}
+	var err error
+	for key, value := range settings {
+		stringValue := fmt.Sprint(value)
+		switch key {
+		case "chance":
+			model.Chance, err = strconv.ParseFloat(stringValue, 64)
+		case "distribution":
+			model.Distribution = stringValue
+		case "delay":
+			model.Delay, err = time.ParseDuration(stringValue)
+		case "min":
+			model.Min, err = time.ParseDuration(stringValue)
+		case "max":
+			model.Max, err = time.ParseDuration(stringValue)
+		case "mean":
+			model.Mean, err = time.ParseDuration(stringValue)
+		case "stdDev":
+			model.StdDev, err = time.ParseDuration(stringValue)
+		case "median":
+			model.Median, err = time.ParseDuration(stringValue)
+		case "sigma":
+			model.Sigma, err = strconv.ParseFloat(stringValue, 64)
+		case "scale":
+			model.Scale, err = time.ParseDuration(stringValue)
+		case "alpha":
+			model.Alpha, err = strconv.ParseFloat(stringValue, 64)
+		default:
+			err = fmt.Errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return model, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	switch model.Distribution {
+	case "constant", "normal", "lognormal":
+	case "uniform":
+		if model.Max < model.Min {
+			return model, fmt.Errorf("uniform needs max above min")
+		}
+	case "pareto":
+		if model.Alpha <= 0 {
+			return model, fmt.Errorf("pareto needs a positive alpha")
+		}
+	default:
+		return model, fmt.Errorf("unknown distribution %q", model.Distribution)
+	}
+	return model, nil
+}
@@ -0,0 +1,48 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// the stores the ledger of telegrams may be kept in
+const (
+	LedgerNone   = "none"
+	LedgerMemory = "memory"
+	LedgerBolt   = "bolt"
+)
+
+// LedgerPolicy says where the telegrams sent are recorded
+type LedgerPolicy struct {
+	Kind string // memory, bolt or none for no ledger
+	Size int    // how many telegrams are kept, the oldest are forgotten
+	Path string // the database file for bolt
+}
+
+// Ledger is the policy read from GENTEEL_LEDGER_* environment variables
+var Ledger LedgerPolicy
+
+// initLedger reads the ledger's policy
+func initLedger() error {
+	var err error
+	switch Ledger.Kind = GetEnv("GENTEEL_LEDGER", LedgerMemory); Ledger.Kind {
+	case LedgerNone, LedgerMemory, LedgerBolt:
+	default:
+		return fmt.Errorf("unknown GENTEEL_LEDGER %q", Ledger.Kind)
+	}
+	if Ledger.Size, err = strconv.Atoi(GetEnv("GENTEEL_LEDGER_SIZE", "1000")); err != nil || Ledger.Size < 1 {
+		return fmt.Errorf("invalid GENTEEL_LEDGER_SIZE")
+	}
+	Ledger.Path = GetEnv("GENTEEL_LEDGER_PATH", "genteelbeacon.ledger")
+	return nil
+}
+
+// Ledgering tells whether we keep a ledger of the telegrams sent
+func Ledgering() bool {
+	return Ledger.Kind != LedgerNone && (GenteelRole == "telegraphist" || GenteelRole == "schildwaechter")
+}
@@ -0,0 +1,46 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// ChaosOverride replaces the chaos flags locally, e.g. set via the admin API.
+// Gates it doesn't mention keep the flag's chance.
+type ChaosOverride struct {
+	ChaosMode bool               `json:"chaosMode"`
+	Gates     map[string]float64 `json:"gates,omitempty"`
+}
+
+var chaosOverride atomic.Pointer[ChaosOverride]
+
+// SetChaosOverride overrides the chaos flags, nil goes back to the flags
+func SetChaosOverride(override *ChaosOverride) {
+	chaosOverride.Store(override)
+}
+
+// ChaosState tells the chaos settings in effect, as far as they don't depend on the request
+func ChaosState() map[string]any {
+	return map[string]any{
+		"chaosMode": chaosMode.Load(),
+		"gates":     maps.Clone(*chaosCache.Load()),
+		"latency":   maps.Clone(*latencyCache.Load()),
+		"faults":    maps.Clone(*faultCache.Load()),
+		"targeting": ChaosTargeting,
+		"override":  chaosOverride.Load(),
+	}
+}
+
+// ResourceModels tells the models of all resources in effect
+func ResourceModels() map[string]ResourceModel {
+	models := make(map[string]ResourceModel)
+	for _, spec := range Resources() {
+		models[spec.Name] = GetResourceModel(spec.Name)
+	}
+	return models
+}
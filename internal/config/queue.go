@@ -0,0 +1,60 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// the queues telegrams posted to /telegram may go through
+const (
+	QueueNone   = "none"
+	QueueMemory = "memory"
+	QueueNats   = "nats"
+)
+
+// QueuePolicy says how telegrams are dispatched asynchronously
+type QueuePolicy struct {
+	Kind     string // memory, nats or none for no asynchronous dispatch
+	Capacity int    // how many telegrams may wait, more are refused
+	Workers  int    // how many telegrams are written at the same time
+	NatsURL  string // the NATS server to use, an embedded one if empty
+	NatsPort int    // where the embedded NATS server listens for other beacons, 0 for in-process only
+	Subject  string // the NATS subject telegrams are sent to
+}
+
+// Queue is the policy read from GENTEEL_QUEUE_* environment variables
+var Queue QueuePolicy
+
+// initQueue reads the queue's policy
+func initQueue() error {
+	var err error
+	switch Queue.Kind = GetEnv("GENTEEL_QUEUE", QueueMemory); Queue.Kind {
+	case QueueNone, QueueMemory, QueueNats:
+	default:
+		return fmt.Errorf("unknown GENTEEL_QUEUE %q", Queue.Kind)
+	}
+	if Queue.Capacity, err = strconv.Atoi(GetEnv("GENTEEL_QUEUE_CAPACITY", "100")); err != nil || Queue.Capacity < 1 {
+		return fmt.Errorf("invalid GENTEEL_QUEUE_CAPACITY")
+	}
+	if Queue.Workers, err = strconv.Atoi(GetEnv("GENTEEL_QUEUE_WORKERS", "4")); err != nil || Queue.Workers < 1 {
+		return fmt.Errorf("invalid GENTEEL_QUEUE_WORKERS")
+	}
+	Queue.NatsURL = GetEnv("GENTEEL_NATS_URL", "")
+	if natsPort := GetEnv("GENTEEL_NATS_PORT", ""); natsPort != "" {
+		if Queue.NatsPort, err = strconv.Atoi(natsPort); err != nil || Queue.NatsPort < 0 {
+			return fmt.Errorf("invalid GENTEEL_NATS_PORT")
+		}
+	}
+	Queue.Subject = GetEnv("GENTEEL_NATS_SUBJECT", "genteelbeacon.telegrams")
+	return nil
+}
+
+// Dispatching tells whether we write telegrams posted to /telegram from a queue
+func Dispatching() bool {
+	return Queue.Kind != QueueNone && (GenteelRole == "telegraphist" || GenteelRole == "schildwaechter")
+}
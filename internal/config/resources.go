@@ -0,0 +1,270 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceModel describes how a consumable resource builds up, drains and trips
+type ResourceModel struct {
+	Increment     float64       // units per request, the fraction is the chance of one more
+	DrainRate     int64         // units drained per interval
+	DrainInterval time.Duration // time between draining
+	TripThreshold int64         // level from which on the gate may trip
+	TripCurve     string        // linear, exponential or step
+	Ceiling       int64         // maximum level, tripping is certain here
+}
+
+// ResourceSpec declares a consumable resource and where it is used
+type ResourceSpec struct {
+	Name        string   // e.g. ink, also used for GENTEEL_INK_* and the inkModel flag
+	GateName    string   // name of the gate's span, e.g. InkWell
+	LatencyGate string   // flag delaying the gate, e.g. wellLatency
+	Metric      string   // gauge name, the Prometheus one gets a _p suffix
+	Description string   // gauge description
+	TripMessage string   // the error when the gate trips
+	Endpoints   []string // the endpoints consulting the gate
+	Readiness   bool     // whether saturation makes the beacon unready
+}
+
+var (
+	// ink and grease are always around, more can be declared with GENTEEL_RESOURCES
+	builtinResources = []ResourceSpec{
+		{
+			Name:        "ink",
+			GateName:    "InkWell",
+			LatencyGate: "wellLatency",
+			Metric:      "genteelbeacon_inkdepletion",
+			Description: "The Genteel Beacon's current ink depletion",
+			TripMessage: "ink well running dry 🐙",
+			Endpoints:   []string{"telegram"},
+			Readiness:   true,
+		},
+		{
+			Name:        "grease",
+			GateName:    "GreaseGrate",
+			LatencyGate: "grateLatency",
+			Metric:      "genteelbeacon_greasebuildup",
+			Description: "The Genteel Beacon's current grease buildup",
+			TripMessage: "grease grate clogged 💀",
+			Endpoints:   []string{"timestamp"},
+			Readiness:   true,
+		},
+	}
+	resourceSpecs []ResourceSpec
+	// the models as configured in the environment, flagd may override them
+	baseResourceModels = map[string]ResourceModel{
+		"grease": {
+			Increment:     0.4,
+			DrainRate:     1,
+			DrainInterval: time.Second,
+			TripThreshold: 90,
+			TripCurve:     "linear",
+			Ceiling:       100,
+		},
+	}
+	defaultResourceModel = ResourceModel{
+		Increment:     1,
+		DrainRate:     1,
+		DrainInterval: time.Second,
+		TripThreshold: 90,
+		TripCurve:     "linear",
+		Ceiling:       100,
+	}
+	resourceModels = map[string]*atomic.Pointer[ResourceModel]{}
+	resourceName   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Resources returns all declared resources
+func Resources() []ResourceSpec {
+	return resourceSpecs
+}
+
+// GetResourceModel returns the model currently in effect for a resource
+func GetResourceModel(name string) ResourceModel {
+	return *resourceModels[name].Load()
+}
+
+// Consumption returns the number of units a single request uses up
+func (m ResourceModel) Consumption() int64 {
+	whole, fraction := math.Modf(m.Increment)
+	units := int64(whole)
+	if rand.Float64() < fraction {
+		units++
+	}
+	return units
+}
+
+// TripChance returns the chance (between 0 and 1) to trip at the given level
+func (m ResourceModel) TripChance(level int64) float64 {
+	if level < m.TripThreshold {
+		return 0
+	}
+	if m.Ceiling <= m.TripThreshold || level >= m.Ceiling {
+		return 1
+	}
+	// how far we are between threshold and ceiling
+	x := float64(level-m.TripThreshold) / float64(m.Ceiling-m.TripThreshold)
+	switch m.TripCurve {
+	case "step":
+		return 1
+	case "exponential":
+		return math.Expm1(5*x) / math.Expm1(5)
+	default:
+		return x
+	}
+}
+
+// Saturated tells whether the level is too high to take on more work
+func (m ResourceModel) Saturated(level int64) bool {
+	return level >= m.TripThreshold+(m.Ceiling-m.TripThreshold)/2
+}
+
+// initResources declares the resources and reads their models from GENTEEL_<RESOURCE>_* environment variables
+func initResources() error {
+	resourceSpecs = append([]ResourceSpec{}, builtinResources...)
+	for _, name := range strings.Split(GetEnv("GENTEEL_RESOURCES", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !resourceName.MatchString(name) {
+			return fmt.Errorf("invalid resource name %q, use lowercase letters, digits and underscores", name)
+		}
+		if slices.ContainsFunc(resourceSpecs, func(spec ResourceSpec) bool { return spec.Name == name }) {
+			return fmt.Errorf("resource %s declared twice", name)
+		}
+		// e.g. steam_pressure becomes SteamPressureGate
+		gateName := ""
+		for part := range strings.SplitSeq(name, "_") {
+			if part != "" {
+				gateName += strings.ToUpper(part[:1]) + part[1:]
+			}
+		}
+		resourceSpecs = append(resourceSpecs, ResourceSpec{
+			Name:        name,
+			GateName:    gateName + "Gate",
+			LatencyGate: name + "Latency",
+			Metric:      "genteelbeacon_" + name,
+			Description: "The Genteel Beacon's current " + strings.ReplaceAll(name, "_", " ") + " level",
+			TripMessage: strings.ReplaceAll(name, "_", " ") + " gate tripped ⚙️",
+			Endpoints:   []string{"telegram"},
+			Readiness:   true,
+		})
+	}
+
+	for i, spec := range resourceSpecs {
+		prefix := "GENTEEL_" + strings.ToUpper(spec.Name) + "_"
+		if endpoints, ok := os.LookupEnv(prefix + "ENDPOINTS"); ok {
+			resourceSpecs[i].Endpoints = nil
+			for endpoint := range strings.SplitSeq(endpoints, ",") {
+				if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+					resourceSpecs[i].Endpoints = append(resourceSpecs[i].Endpoints, endpoint)
+				}
+			}
+		}
+		if readiness, ok := os.LookupEnv(prefix + "READINESS"); ok {
+			var err error
+			if resourceSpecs[i].Readiness, err = strconv.ParseBool(readiness); err != nil {
+				return fmt.Errorf("%s readiness: %w", spec.Name, err)
+			}
+		}
+
+		model, known := baseResourceModels[spec.Name]
+		if !known {
+			model = defaultResourceModel
+		}
+		err := applyResourceSettings(&model, func(key string) (string, bool) {
+			return os.LookupEnv(prefix + strings.ToUpper(key))
+		})
+		if err != nil {
+			return fmt.Errorf("%s model: %w", spec.Name, err)
+		}
+		baseResourceModels[spec.Name] = model
+		resourceModels[spec.Name] = &atomic.Pointer[ResourceModel]{}
+		resourceModels[spec.Name].Store(&model)
+	}
+	return nil
+}
+
+// applyResourceSettings changes the model according to the settings found
+func applyResourceSettings(model *ResourceModel, setting func(key string) (string, bool)) error {
+	var err error
+	if value, ok := setting("increment"); ok {
+		if model.Increment, err = strconv.ParseFloat(value, 64); err != nil {
+			return err
+		}
+	}
+	if value, ok := setting("drain_rate"); ok {
+		if model.DrainRate, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return err
+		}
+	}
+	if value, ok := setting("drain_interval"); ok {
+		if model.DrainInterval, err = time.ParseDuration(value); err != nil {
+			return err
+		}
+	}
+	if value, ok := setting("trip_threshold"); ok {
+		if model.TripThreshold, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return err
+		}
+	}
+	if value, ok := setting("trip_curve"); ok {
+		model.TripCurve = value
+	}
+	if value, ok := setting("ceiling"); ok {
+		if model.Ceiling, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	if model.Increment < 0 || model.DrainRate < 0 {
+		return fmt.Errorf("increment and drain rate must not be negative")
+	}
+	if model.DrainInterval <= 0 {
+		return fmt.Errorf("drain interval must be positive")
+	}
+	if model.TripCurve != "linear" && model.TripCurve != "exponential" && model.TripCurve != "step" {
+		return fmt.Errorf("unknown trip curve %q", model.TripCurve)
+	}
+	if model.Ceiling < model.TripThreshold {
+		return fmt.Errorf("ceiling must not be below the trip threshold")
+	}
+	return nil
+}
+
+// overrideResourceModel applies the settings of a flagd object flag on top of the environment
+func overrideResourceModel(name string, settings map[string]any) error {
+	model := baseResourceModels[name]
+	err := applyResourceSettings(&model, func(key string) (string, bool) {
+		// flags use camelCase, e.g. drainInterval
+		parts := strings.Split(key, "_")
+		for i := 1; i < len(parts); i++ {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+		value, ok := settings[strings.Join(parts, "")]
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprint(value), true
+	})
+	if err != nil {
+		return err
+	}
+	resourceModels[name].Store(&model)
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
@@ -45,6 +46,11 @@ var (
 	nameSpace      string
 )
 
+// logger is the gearsmith's own, with its own level
+func logger() *slog.Logger {
+	return o11y.Component(o11y.ComponentGearsmith)
+}
+
 // GetNamespace is get the pod's namespace
 func GetNamespace() (string, error) {
 	nsBytes, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
@@ -62,7 +68,7 @@ func GetNamespace() (string, error) {
 func getBeacons(clientset *kubernetes.Clientset) ([]string, error) {
 	deployments, err := clientset.AppsV1().Deployments(nameSpace).List(context.TODO(), metav1.ListOptions{LabelSelector: "genteelbeacon"})
 	if err != nil {
-		o11y.Logger.Error("Error getting deployments! " + err.Error())
+		logger().Error("Error getting deployments! " + err.Error())
 		return nil, err
 	}
 	var deploymentNames []string
@@ -71,7 +77,7 @@ func getBeacons(clientset *kubernetes.Clientset) ([]string, error) {
 		deploymentNames = append(deploymentNames, deploy.Name)
 	}
 
-	o11y.Logger.Debug(fmt.Sprintf("Deployments: %+v", deploymentNames))
+	logger().Debug(fmt.Sprintf("Deployments: %+v", deploymentNames))
 
 	return deploymentNames, nil
 }
@@ -80,7 +86,7 @@ func getBeacons(clientset *kubernetes.Clientset) ([]string, error) {
 func calcValues(beacon string, clientset *kubernetes.Clientset) (int64, float64, int64, float64, error) {
 	pods, err := clientset.CoreV1().Pods(nameSpace).List(context.TODO(), metav1.ListOptions{LabelSelector: "genteelbeacon=" + beacon})
 	if err != nil {
-		o11y.Logger.Error("Error getting pods for label genteelbeacon=" + beacon)
+		logger().Error("Error getting pods for label genteelbeacon=" + beacon)
 		return 0, 0, 0, 0, err
 	}
 
@@ -89,17 +95,17 @@ func calcValues(beacon string, clientset *kubernetes.Clientset) (int64, float64,
 	var gearSum float64 = 0
 	var inkSum float64 = 0
 	for _, pod := range pods.Items {
-		o11y.Logger.Debug("Querying " + pod.Name + " at IP " + pod.Status.PodIP)
+		logger().Debug("Querying " + pod.Name + " at IP " + pod.Status.PodIP)
 		req, err := http.NewRequest("GET", "http://"+pod.Status.PodIP+":1337/metrics", nil)
 		if err != nil {
-			o11y.Logger.Warn("Can't create request for pod " + pod.Name + ". Error: " + err.Error())
+			logger().Warn("Can't create request for pod " + pod.Name + ". Error: " + err.Error())
 			continue // we just ignore this pod
 		}
 
 		client := &http.Client{Timeout: 3 * time.Second}
 		resp, err := client.Do(req)
 		if err != nil {
-			o11y.Logger.Warn("Can't reach pod " + pod.Name + ". Error: " + err.Error())
+			logger().Warn("Can't reach pod " + pod.Name + ". Error: " + err.Error())
 			continue // we just ignore this pod
 		}
 
@@ -112,12 +118,12 @@ func calcValues(beacon string, clientset *kubernetes.Clientset) (int64, float64,
 			if strings.HasPrefix(line, "genteelbeacon_greasebuildup_p") {
 				parts := strings.Fields(line)
 				if len(parts) < 2 {
-					o11y.Logger.Error("Error parsing prometheus value: malformed line")
+					logger().Error("Error parsing prometheus value: malformed line")
 					continue
 				}
 				greaseReturn, err := strconv.ParseFloat(parts[len(parts)-1], 64)
 				if err != nil {
-					o11y.Logger.Error("Error parsing prometheus value for grease")
+					logger().Error("Error parsing prometheus value for grease")
 				} else {
 					greaseVal = greaseReturn
 					gearNumber += 1
@@ -127,12 +133,12 @@ func calcValues(beacon string, clientset *kubernetes.Clientset) (int64, float64,
 			if strings.HasPrefix(line, "genteelbeacon_inkdepletion_p") {
 				parts := strings.Fields(line)
 				if len(parts) < 2 {
-					o11y.Logger.Error("Error parsing prometheus value: malformed line")
+					logger().Error("Error parsing prometheus value: malformed line")
 					continue
 				}
 				inkReturn, err := strconv.ParseFloat(parts[len(parts)-1], 64)
 				if err != nil {
-					o11y.Logger.Error("Error parsing prometheus value for ink")
+					logger().Error("Error parsing prometheus value for ink")
 				} else {
 					inkVal = inkReturn
 					inkNumber += 1
@@ -141,7 +147,7 @@ func calcValues(beacon string, clientset *kubernetes.Clientset) (int64, float64,
 			}
 		}
 		resp.Body.Close() // Closing explicitly after processing
-		o11y.Logger.Debug(fmt.Sprintf("Grease Buildup for "+pod.Name+" is %f\n", (greaseVal)) + fmt.Sprintf("Ink Depletion for "+pod.Name+" is %f\n", (inkVal)))
+		logger().Debug(fmt.Sprintf("Grease Buildup for "+pod.Name+" is %f\n", (greaseVal)) + fmt.Sprintf("Ink Depletion for "+pod.Name+" is %f\n", (inkVal)))
 	}
 
 	return gearNumber, gearSum, inkNumber, inkSum, nil
@@ -154,7 +160,7 @@ func statsServe(w http.ResponseWriter, r *http.Request) {
 		Inkwells map[string]inkStat
 	}
 	statsLock.RLock()
-	o11y.Logger.Info("Combined stats", "gears", gearStats, "inkwells", inkStats)
+	logger().Info("Combined stats", "gears", gearStats, "inkwells", inkStats)
 	combinedStats := combinedStat{gearStats, inkStats}
 	statsLock.RUnlock()
 	jsonString, _ := json.Marshal(combinedStats)
@@ -169,7 +175,7 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 func valueServe(w http.ResponseWriter, r *http.Request) {
 	beacon := r.PathValue("beacon")
 	if beacon == "" {
-		o11y.Logger.Warn("No beacon in query!")
+		logger().Warn("No beacon in query!")
 	}
 	// get the right sum
 	var returnSum float64 = 0
@@ -177,13 +183,13 @@ func valueServe(w http.ResponseWriter, r *http.Request) {
 	if r.PathValue("valuename") == "gearvalue" {
 		beaconGear := gearStats[beacon]
 		if beaconGear.Count == 0 {
-			o11y.Logger.Warn("Queried non-existent gear: " + beacon)
+			logger().Warn("Queried non-existent gear: " + beacon)
 		}
 		returnSum = beaconGear.Sum
 	} else if r.PathValue("valuename") == "inkvalue" {
 		beaconInk := inkStats[beacon]
 		if beaconInk.Count == 0 {
-			o11y.Logger.Warn("Queried non-existent ink: " + beacon)
+			logger().Warn("Queried non-existent ink: " + beacon)
 		}
 		returnSum = beaconInk.Sum
 	} else {
@@ -207,22 +213,22 @@ func valueServe(w http.ResponseWriter, r *http.Request) {
 				"apiVersion": "v1beta1",
 			},
 			"metricName": r.PathValue("valuename"),
-			"timestamp":  fmt.Sprintf(time.Now().Format(time.RFC3339)),
+			"timestamp":  time.Now().Format(time.RFC3339),
 			"value":      fmt.Sprintf("%d", int64(math.Round(returnSum))),
 		},
 		}}
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		o11y.Logger.Error(fmt.Sprintf("could not marshal json: %s\n", err.Error()))
+		logger().Error(fmt.Sprintf("could not marshal json: %s\n", err.Error()))
 		return
 	}
 
 	fmt.Fprint(w, string(jsonData))
 }
 
-// reach out to cluster and get what we want
-func setStats() {
-	for {
+// reach out to cluster and get what we want, until the context is done
+func setStats(ctx context.Context) {
+	for ctx.Err() == nil {
 		config, err := rest.InClusterConfig()
 		if err != nil {
 			panic(err.Error())
@@ -233,12 +239,12 @@ func setStats() {
 		}
 		beacons, err := getBeacons(clientset)
 		if err != nil {
-			o11y.Logger.Error(err.Error())
+			logger().Error(err.Error())
 		}
 		for _, element := range beacons {
 			gearNumber, gearSum, inkNumber, inkSum, err := calcValues(element, clientset)
 			if err != nil {
-				o11y.Logger.Error(err.Error())
+				logger().Error(err.Error())
 			}
 			var gearAverage float64 = 0
 			var inkAverage float64 = 0
@@ -253,15 +259,18 @@ func setStats() {
 			gearStats[element] = gearStat{gearNumber, gearSum, gearAverage}
 			inkStats[element] = inkStat{inkNumber, inkSum, inkAverage}
 			statsLock.Unlock()
-			o11y.Logger.Debug(fmt.Sprintf("Gear average for "+element+" is: %f\n", (gearAverage)))
-			o11y.Logger.Debug(fmt.Sprintf("Ink average for "+element+" is: %f\n", (inkAverage)))
+			logger().Debug(fmt.Sprintf("Gear average for "+element+" is: %f\n", (gearAverage)))
+			logger().Debug(fmt.Sprintf("Ink average for "+element+" is: %f\n", (inkAverage)))
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
 		}
-		time.Sleep(5 * time.Second)
 	}
 }
 
-// RunGearsmith is what we run in gearsmith mode
-func RunGearsmith() {
+// RunGearsmith is what we run in gearsmith mode, until the context is done
+func RunGearsmith(ctx context.Context, shutdownTimeout time.Duration) error {
 	gearStats = make(map[string]gearStat)
 	inkStats = make(map[string]inkStat)
 
@@ -270,16 +279,30 @@ func RunGearsmith() {
 		panic(err.Error())
 	}
 	nameSpace = ns
-	o11y.Logger.Debug("Running in namespace: " + nameSpace)
+	logger().Debug("Running in namespace: " + nameSpace)
 	// run in background
-	go setStats()
+	go setStats(ctx)
 
 	router := http.NewServeMux()
 	router.HandleFunc("/stats", statsServe)
 	router.HandleFunc("/apis/custom.metrics.k8s.io/v1beta1", healthCheck)
 	router.HandleFunc("/apis/custom.metrics.k8s.io/v1beta1/namespaces/"+ns+"/services/{beacon}/{valuename}", valueServe)
 
-	http.ListenAndServeTLS(":6443", "/cert/tls.crt", "/cert/tls.key", router)
+	server := &http.Server{Addr: ":6443", Handler: router}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServeTLS("/cert/tls.crt", "/cert/tls.key")
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	logger().Info("Shutting down, draining requests 🚿")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
 }
 
 // kubectl get --raw /apis/custom.metrics.k8s.io/v1beta1/namespaces/genteelbeacon/services/velvettimepiece/gearvalue
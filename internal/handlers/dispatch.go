@@ -0,0 +1,188 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/queue"
+
+	"github.com/gofiber/fiber/v2"
+	slogfiber "github.com/samber/slog-fiber"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the headers besides the trace context
+const (
+	headerRequestID = "genteel-request-id"
+	headerEnqueued  = "genteel-enqueued"
+)
+
+var (
+	dispatchQueue   queue.Queue
+	dispatchWorkers sync.WaitGroup
+	stopWorkers     context.CancelFunc
+	// gives up the telegrams being written, once there's no more time
+	abandonDeliveries context.CancelFunc
+	// once we shut down, telegrams posted would be lost
+	dispatchClosed atomic.Bool
+)
+
+// StartDispatch sets up the queue for telegrams posted to /telegram and the workers writing them
+func StartDispatch() error {
+	var err error
+	if dispatchQueue, err = queue.New(); err != nil {
+		return err
+	}
+	var ctx, deliveryCtx context.Context
+	ctx, stopWorkers = context.WithCancel(context.Background())
+	deliveryCtx, abandonDeliveries = context.WithCancel(context.Background())
+	for range config.Queue.Workers {
+		dispatchWorkers.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case message := <-dispatchQueue.Messages():
+					deliverTelegram(deliveryCtx, message)
+				}
+			}
+		})
+	}
+	o11y.Logger.Info(fmt.Sprintf("Dispatching telegrams via %s with %d workers", dispatchQueue.System(), config.Queue.Workers))
+	return nil
+}
+
+// StopDispatch refuses further telegrams, lets the workers empty the queue until ctx is done, and closes it.
+// The telegrams still being written when ctx is done are abandoned.
+func StopDispatch(ctx context.Context) {
+	if dispatchQueue == nil {
+		return
+	}
+	dispatchClosed.Store(true)
+	for dispatchQueue.Depth() > 0 && ctx.Err() == nil {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+		}
+	}
+	stopWorkers()
+	stopAbandoning := context.AfterFunc(ctx, abandonDeliveries)
+	dispatchWorkers.Wait()
+	stopAbandoning()
+	abandonDeliveries()
+	if left := dispatchQueue.Depth(); left > 0 {
+		o11y.Logger.Warn(fmt.Sprintf("%d telegrams left in the queue", left))
+	}
+	dispatchQueue.Close()
+}
+
+// QueueDepth tells how many telegrams are waiting to be written
+func QueueDepth() int64 {
+	if dispatchQueue == nil {
+		return 0
+	}
+	return int64(dispatchQueue.Depth())
+}
+
+// handleTelegramDispatch puts the telegram on the queue for the workers, the body may hold the words to transcribe
+func handleTelegramDispatch(c *fiber.Ctx) error {
+	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "TelegramDispatch", trace.WithSpanKind(trace.SpanKindProducer))
+	requestID := slogfiber.GetRequestIDFromContext(c.Context())
+	span.SetAttributes(attribute.String("RequestID", requestID))
+	defer span.End()
+
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
+	}
+	if dispatchQueue == nil {
+		return fiber.NewError(fiber.StatusNotImplemented, "No queue to dispatch telegrams with")
+	}
+	if dispatchClosed.Load() {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "The post office is closing 📮")
+	}
+	if len(c.Body()) > maxTelegraphMessage {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "We transcribe telegrams, not novels")
+	}
+	span.SetAttributes(
+		attribute.String("messaging.system", dispatchQueue.System()),
+		attribute.String("messaging.destination.name", dispatchQueue.Destination()),
+		attribute.String("messaging.operation.type", "send"),
+		attribute.String("messaging.message.id", requestID),
+	)
+
+	// the body is only ours until the handler returns
+	message := queue.Message{
+		Headers: map[string]string{
+			headerRequestID: requestID,
+			headerEnqueued:  time.Now().Format(time.RFC3339Nano),
+		},
+		Body: []byte(strings.TrimSpace(string(c.Body()))),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(message.Headers))
+	if err := dispatchQueue.Publish(ctx, message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if errors.Is(err, queue.ErrQueueFull) {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "The post office is overflowing 📮")
+		}
+		return err
+	}
+	span.AddEvent("Telegram queued")
+	o11y.Logger.DebugContext(ctx, "Telegram queued", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	if config.Ledgering() {
+		// where to find out how it went
+		c.Location("/telegrams/" + requestID)
+	}
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{"Identifier": requestID, "Status": "queued"})
+}
+
+// deliverTelegram writes the telegram taken from the queue, in a trace of its own linked to the one that queued it,
+// unless the delivery is abandoned
+func deliverTelegram(deliveryCtx context.Context, message queue.Message) {
+	producerCtx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(message.Headers))
+	ctx, span := otel.Tracer(config.AppName).Start(deliveryCtx, "TelegramDelivery",
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithLinks(trace.LinkFromContext(producerCtx)))
+	defer span.End()
+	requestID := message.Headers[headerRequestID]
+	span.SetAttributes(
+		attribute.String("RequestID", requestID),
+		attribute.String("messaging.system", dispatchQueue.System()),
+		attribute.String("messaging.destination.name", dispatchQueue.Destination()),
+		attribute.String("messaging.operation.type", "process"),
+		attribute.String("messaging.message.id", requestID),
+	)
+	if enqueued, err := time.Parse(time.RFC3339Nano, message.Headers[headerEnqueued]); err == nil {
+		waited := time.Since(enqueued)
+		span.SetAttributes(attribute.String("genteel.queue.waited", waited.String()))
+		o11y.RecordServiceDuration(ctx, "TelegramQueue", waited, nil)
+	}
+
+	if _, err := writeTelegram(ctx, requestID, string(message.Body)); err != nil {
+		// nobody is waiting for it, so we can only tell
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Logger.WarnContext(ctx, "Telegram "+requestID+" not delivered: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		return
+	}
+	o11y.RecordDelivery(ctx, "telegram")
+	o11y.Logger.DebugContext(ctx, "Telegram "+requestID+" delivered", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+}
@@ -0,0 +1,301 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/agitator"
+	"github.com/schildwaechter/genteelbeacon/internal/beaconrpc"
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// the gRPC status for the HTTP status of our errors
+var grpcCodes = map[int]grpccodes.Code{
+	fiber.StatusBadRequest:          grpccodes.InvalidArgument,
+	fiber.StatusTeapot:              grpccodes.Unavailable,
+	fiber.StatusTooManyRequests:     grpccodes.ResourceExhausted,
+	fiber.StatusInternalServerError: grpccodes.Internal,
+	fiber.StatusBadGateway:          grpccodes.Unavailable,
+	fiber.StatusServiceUnavailable:  grpccodes.Unavailable,
+	fiber.StatusGatewayTimeout:      grpccodes.DeadlineExceeded,
+}
+
+// the request ID, as slog-fiber keeps it for HTTP
+type requestIDKey struct{}
+
+// beaconServer answers the gRPC API with the same services as the HTTP routes
+type beaconServer struct {
+	beaconrpc.UnimplementedBeaconServer
+}
+
+// readinessHealth reports not serving while draining or saturated, like /readyz
+type readinessHealth struct {
+	*health.Server
+	draining *atomic.Bool
+}
+
+func (h readinessHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if Draining(h.draining) || services.Saturated() {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return h.Server.Check(ctx, req)
+}
+
+// NewGrpcServer sets up the gRPC API with health and reflection, traced by otelgrpc
+func NewGrpcServer(draining *atomic.Bool) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(grpcContext, grpcDuration, grpcRecover),
+	)
+	beaconrpc.RegisterBeaconServer(server, beaconServer{})
+	healthpb.RegisterHealthServer(server, readinessHealth{Server: health.NewServer(), draining: draining})
+	reflection.Register(server)
+	return server
+}
+
+// grpcContext adds the request ID and the evaluation context for the chaos flags, like chaosContext does for HTTP
+func grpcContext(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := make(map[string]string)
+	for key, values := range md {
+		headers[key] = strings.Join(values, ",")
+	}
+	requestID := headers[strings.ToLower(fiber.HeaderXRequestID)]
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	var traceID string
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		traceID = spanContext.TraceID().String()
+	}
+	evalCtx := config.ChaosEvaluationContext(requestID, info.FullMethod, traceID, headers)
+	ctx = openfeature.WithTransactionContext(context.WithValue(ctx, requestIDKey{}, requestID), evalCtx)
+	return handler(ctx, req)
+}
+
+// grpcDuration records the call's latency for the method, with the gRPC status code as status
+func grpcDuration(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	o11y.RecordEndpointDuration(ctx, info.FullMethod, "GRPC", int(status.Code(err)), time.Since(start))
+	return resp, err
+}
+
+// grpcRecover turns a panic into an internal error, like the recover middleware does for HTTP
+func grpcRecover(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			o11y.Logger.ErrorContext(ctx, fmt.Sprint("Recovered from panic: ", r))
+			err = status.Errorf(grpccodes.Internal, "%v", r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcRequestID is the request's ID, taken from the x-request-id metadata if given
+func grpcRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// grpcError translates our errors to gRPC, the span shows the status as well
+func grpcError(span trace.Span, err error) error {
+	code := grpccodes.Unknown
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		if grpcCode, ok := grpcCodes[fiberErr.Code]; ok {
+			code = grpcCode
+		}
+	}
+	span.SetStatus(codes.Error, err.Error())
+	return status.Error(code, err.Error())
+}
+
+// notMyJob is the answer for the wrong role
+func notMyJob(span trace.Span) error {
+	span.SetStatus(codes.Error, "Not my job!")
+	return status.Error(grpccodes.Unimplemented, "Not my job!")
+}
+
+func (beaconServer) Telegram(ctx context.Context, _ *beaconrpc.TelegramRequest) (*beaconrpc.TelegramReply, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "TelegramRPC")
+	span.SetAttributes(attribute.String("RequestID", grpcRequestID(ctx)))
+	defer span.End()
+
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return nil, notMyJob(span)
+	}
+
+	clerkMessage, clerkErr := writeTelegram(ctx, grpcRequestID(ctx), "")
+	// running out of ink is exhausting
+	if errors.As(clerkErr, new(gateError)) {
+		span.SetStatus(codes.Error, clerkErr.Error())
+		return nil, status.Error(grpccodes.ResourceExhausted, clerkErr.Error())
+	}
+	if clerkErr != nil {
+		return nil, grpcError(span, clerkErr)
+	}
+
+	o11y.RecordDelivery(ctx, "telegram")
+	return &beaconrpc.TelegramReply{
+		Message:        clerkMessage.Message,
+		Emoji:          clerkMessage.Emoji,
+		FormVersion:    clerkMessage.FormVersion,
+		Service:        clerkMessage.Service,
+		Telegraphist:   clerkMessage.Telegraphist,
+		Identifier:     clerkMessage.Identifier,
+		ClockReference: clerkMessage.ClockReference,
+		Timestamp:      clerkMessage.Timestamp,
+	}, nil
+}
+
+func (beaconServer) Timestamp(ctx context.Context, _ *beaconrpc.TimestampRequest) (*beaconrpc.ClockReading, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "TimestampRPC")
+	span.SetAttributes(attribute.String("RequestID", grpcRequestID(ctx)))
+	defer span.End()
+
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "clock" && config.GenteelRole != "schildwaechter" {
+		return nil, notMyJob(span)
+	}
+
+	// too much grease is exhausting as well
+	if gateErr := services.ConsultGates(ctx, "timestamp"); gateErr != nil {
+		span.SetStatus(codes.Error, gateErr.Error())
+		return nil, status.Error(grpccodes.ResourceExhausted, gateErr.Error())
+	}
+
+	reading := readClock()
+	return &beaconrpc.ClockReading{TimeReading: reading.TimeReading, ClockName: reading.ClockName}, nil
+}
+
+func (beaconServer) Emission(ctx context.Context, _ *beaconrpc.EmissionRequest) (*beaconrpc.EmissionReply, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "EmissionRPC")
+	span.SetAttributes(attribute.String("RequestID", grpcRequestID(ctx)))
+	defer span.End()
+
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "lightkeeper" && config.GenteelRole != "schildwaechter" {
+		return nil, notMyJob(span)
+	}
+	if gateErr := services.ConsultGates(ctx, "emission"); gateErr != nil {
+		span.SetStatus(codes.Error, gateErr.Error())
+		return nil, status.Error(grpccodes.ResourceExhausted, gateErr.Error())
+	}
+	o11y.Logger.InfoContext(ctx, "Emanating local information with request metadata", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestMetadata := make(map[string]string)
+	for key, values := range md {
+		requestMetadata[key] = strings.Join(values, ",")
+	}
+	scribeResponse, _ := services.FocusedScribe(ctx, grpcRequestID(ctx))
+
+	o11y.RecordDelivery(ctx, "callingcard")
+	return &beaconrpc.EmissionReply{
+		CallingCard: &beaconrpc.CallingCard{
+			Attendant:   scribeResponse.Attendant,
+			Salutation:  scribeResponse.Salutation,
+			CardVersion: scribeResponse.CardVersion,
+			Signature:   scribeResponse.Signature,
+			Identifier:  scribeResponse.Identifier,
+		},
+		RequestMetadata:    requestMetadata,
+		GenteelEnvironment: genteelEnvironment(),
+	}, nil
+}
+
+func (beaconServer) Calamity(ctx context.Context, req *beaconrpc.CalamityRequest) (*beaconrpc.CalamityReply, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "CalamityRPC")
+	span.SetAttributes(attribute.String("RequestID", grpcRequestID(ctx)))
+	defer span.End()
+
+	if config.GenteelRole == "agitator" {
+		return grpcAgitation(ctx, req, span)
+	}
+	if config.GenteelRole != "lightkeeper" && config.GenteelRole != "schildwaechter" {
+		return nil, notMyJob(span)
+	}
+	// causing an error on purpose
+	o11y.Logger.ErrorContext(ctx, "Calamity has been invoked!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	span.SetStatus(codes.Error, "calamity")
+	return nil, status.Error(grpccodes.Internal, "Oh, no! A most dreadful calamity has occurred! 💥")
+}
+
+// grpcAgitation brings about the calamity asked for, by default we exit
+func grpcAgitation(ctx context.Context, req *beaconrpc.CalamityRequest, span trace.Span) (*beaconrpc.CalamityReply, error) {
+	mode := req.GetMode()
+	if mode == "" {
+		mode = "exit"
+	}
+	span.SetAttributes(attribute.String("genteel.calamity", mode))
+	switch mode {
+	case "exit":
+		// this is a bit brutal
+		o11y.Logger.ErrorContext(ctx, "Disrupt!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		os.Exit(133)
+	case "panic":
+		// for grpcRecover to catch
+		o11y.Logger.ErrorContext(ctx, "Panic!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		panic("a most dreadful calamity has occurred 💥")
+	case "slowloris":
+		return nil, status.Error(grpccodes.InvalidArgument, "slowloris needs HTTP")
+	}
+
+	calamity, err := agitator.CalamityFromMap(map[string]string{
+		"mode":     mode,
+		"rate":     req.GetRate(),
+		"cores":    req.GetCores(),
+		"duration": req.GetDuration(),
+	})
+	if err == nil {
+		err = agitator.Unleash(calamity)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, status.Error(grpccodes.InvalidArgument, err.Error())
+	}
+	span.AddEvent("Calamity unleashed")
+
+	running := agitator.Running()
+	reply := &beaconrpc.CalamityReply{}
+	reply.Modes, _ = running["modes"].([]string)
+	for key, target := range map[string]*int64{
+		"leakedBytes":    &reply.LeakedBytes,
+		"leakedFiles":    &reply.LeakedFiles,
+		"leakedRoutines": &reply.LeakedRoutines,
+	} {
+		count, _ := running[key].(int)
+		*target = int64(count)
+	}
+	return reply, nil
+}
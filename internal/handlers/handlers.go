@@ -6,12 +6,16 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/schildwaechter/genteelbeacon/internal/agitator"
 	"github.com/schildwaechter/genteelbeacon/internal/config"
 	"github.com/schildwaechter/genteelbeacon/internal/o11y"
 	"github.com/schildwaechter/genteelbeacon/internal/services"
@@ -19,33 +23,111 @@ import (
 	"github.com/schildwaechter/genteelbeacon/internal/types"
 
 	"github.com/enescakir/emoji"
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/open-feature/go-sdk/openfeature"
 	slogfiber "github.com/samber/slog-fiber"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func RegisterRoutes(app *fiber.App) {
+	// chaos flags may target the request
+	app.Use(func(c *fiber.Ctx) error {
+		return chaosContext(c)
+	})
+	app.Use(func(c *fiber.Ctx) error {
+		return endpointDuration(c)
+	})
+
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Genteel Beacon 🚨")
 	})
 
 	app.Get("/timestamp", func(c *fiber.Ctx) error {
+		if config.GenteelRole == "relay" {
+			return handleRelay(c)
+		}
 		return handleTimestamp(c)
 	})
 
 	app.Get("/telegram", func(c *fiber.Ctx) error {
+		if config.GenteelRole == "relay" {
+			return handleRelay(c)
+		}
 		return handleTelegram(c)
 	})
 
+	// written later by the workers, the body may hold the words
+	app.Post("/telegram", func(c *fiber.Ctx) error {
+		return handleTelegramDispatch(c)
+	})
+
+	// the relay can't pass on a stream
+	app.Get("/telegram/stream", func(c *fiber.Ctx) error {
+		return handleTelegramStream(c)
+	})
+
+	// the ledger is kept by whoever writes the telegrams
+	app.Get("/telegrams", func(c *fiber.Ctx) error {
+		return handleLedger(c)
+	})
+	app.Get("/telegrams/:identifier", func(c *fiber.Ctx) error {
+		return handleLedgerEntry(c)
+	})
+
+	// messages both ways, which the relay can't pass on either
+	app.Get("/telegraph", telegraphUpgrade, websocket.New(handleTelegraph))
+
 	app.Get("/emission", func(c *fiber.Ctx) error {
+		if config.GenteelRole == "relay" {
+			return handleRelay(c)
+		}
 		return handleEmission(c)
 	})
 
 	app.Get("/calamity", func(c *fiber.Ctx) error {
 		return handleCalamity(c)
 	})
+
+	// emulate the configured call graph
+	if config.Topology != nil {
+		app.Get("/mesh/:endpoint", func(c *fiber.Ctx) error {
+			return handleMesh(c)
+		})
+	}
+}
+
+// chaosContext adds the request's evaluation context for the chaos flags
+func chaosContext(c *fiber.Ctx) error {
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		headers[strings.ToLower(string(key))] = string(value)
+	})
+	var traceID string
+	if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.HasTraceID() {
+		traceID = spanContext.TraceID().String()
+	}
+	evalCtx := config.ChaosEvaluationContext(slogfiber.GetRequestIDFromContext(c.Context()), c.Path(), traceID, headers)
+	c.SetUserContext(openfeature.WithTransactionContext(c.UserContext(), evalCtx))
+	return c.Next()
+}
+
+// endpointDuration records the request's latency for the route, with the trace as exemplar
+func endpointDuration(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+	// the error handler sets the status only later
+	status := c.Response().StatusCode()
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+	o11y.RecordEndpointDuration(c.UserContext(), c.Route().Path, c.Method(), status, time.Since(start))
+	return err
 }
 
 func handleTimestamp(c *fiber.Ctx) error {
@@ -59,24 +141,25 @@ func handleTimestamp(c *fiber.Ctx) error {
 	}
 
 	// check whether we have accumulated too much grease
-	greaseErr := services.GreaseGrate(ctx)
-	if greaseErr != nil {
-		return greaseErr
+	gateErr := services.ConsultGates(ctx, "timestamp")
+	if gateErr != nil {
+		return gateErr
 	}
-	services.GreaseChan <- 1
 
-	// prepare the answer with hostname and current time
+	return c.Status(http.StatusOK).JSON(readClock())
+}
+
+// readClock prepares the answer with hostname and current time
+func readClock() types.ClockReading {
 	nodeName, err := os.Hostname()
 	if err != nil {
 		nodeName = "unknown host"
 	}
-	myClockReading := types.ClockReading{
+	return types.ClockReading{
 		//TimeReading: time.Now().UTC().Format("2006-01-02 15:04:05"),
 		TimeReading: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
 		ClockName:   nodeName,
 	}
-
-	return c.Status(http.StatusOK).JSON(myClockReading)
 }
 
 func handleTelegram(c *fiber.Ctx) error {
@@ -90,35 +173,7 @@ func handleTelegram(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
 	}
 
-	// test whether we still have ink
-	inkErr := services.InkWell(ctx)
-	if inkErr != nil {
-		return inkErr
-	}
-	services.InkChan <- 1
-
-	// check whether we use a clock
-	var clockResponseData types.ClockReading
-	var clockResponseError error = nil
-	clock, useClock := os.LookupEnv("GENTEEL_CLOCK")
-
-	if useClock {
-		clockResponseData, clockResponseError = services.NimbleCourier(ctx, clock)
-	} else {
-		// return simplified answer
-		o11y.Logger.DebugContext(ctx, "No clock available")
-		clockResponseData = types.ClockReading{
-			TimeReading: time.Now().UTC().Format("2006-01-02"),
-			ClockName:   "local",
-		}
-	}
-	if clockResponseError != nil {
-		return clockResponseError
-	}
-
-	// actually create the message
-	clerkMessage, clerkErr := services.DiligentClerk(ctx, clockResponseData, useClock, slogfiber.GetRequestIDFromContext(c.Context()))
-
+	clerkMessage, clerkErr := writeTelegram(ctx, slogfiber.GetRequestIDFromContext(c.Context()), "")
 	if clerkErr != nil {
 		return clerkErr
 	}
@@ -126,6 +181,7 @@ func handleTelegram(c *fiber.Ctx) error {
 	// respond with appropriate mimetype
 	offer := c.Accepts(fiber.MIMETextPlain, fiber.MIMETextHTML, fiber.MIMEApplicationJSON)
 	o11y.Logger.DebugContext(ctx, "Offer: "+offer)
+	o11y.RecordDelivery(ctx, "telegram")
 	if offer == "text/html" {
 		c.Set("Content-type", "text/html")
 		return templates.HtmlTelegram(clerkMessage).Render(c.Context(), c.Response().BodyWriter())
@@ -141,6 +197,44 @@ func handleTelegram(c *fiber.Ctx) error {
 	return tmpl.ExecuteTemplate(c.Response().BodyWriter(), "telegramText", clerkMessage)
 }
 
+// gateError is a gate tripped before the telegram was written, not a failure of the clerk
+type gateError struct{ error }
+
+func (e gateError) Unwrap() error { return e.error }
+
+// writeTelegram has the clerk write the telegram if we still have ink, with the time from the clocks if we use any,
+// and the transcript as message unless it's empty, and enters it in the ledger whatever happens
+func writeTelegram(ctx context.Context, requestID string, transcript string) (telegram types.Telegram, err error) {
+	defer func(started time.Time) {
+		services.RecordTelegram(ctx, requestID, telegram, started, err)
+	}(time.Now())
+
+	// test whether we still have ink
+	if gateErr := services.ConsultGates(ctx, "telegram"); gateErr != nil {
+		return types.Telegram{}, gateError{gateErr}
+	}
+
+	var clockResponseData types.ClockReading
+	var clockResponseError error = nil
+	useClock := len(config.Courier.Clocks) > 0
+
+	if useClock {
+		clockResponseData, clockResponseError = services.NimbleCourier(ctx, config.Courier.Clocks)
+		// the courier fell back to the local date
+		useClock = clockResponseData.ClockName != services.LocalClock
+	} else {
+		// return simplified answer
+		o11y.Logger.DebugContext(ctx, "No clock available")
+		clockResponseData = services.LocalReading()
+	}
+	if clockResponseError != nil {
+		return types.Telegram{}, clockResponseError
+	}
+
+	// actually create the message
+	return services.DiligentClerk(ctx, clockResponseData, useClock, requestID, transcript)
+}
+
 func handleEmission(c *fiber.Ctx) error {
 
 	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "EmissionEndpoint")
@@ -150,21 +244,16 @@ func handleEmission(c *fiber.Ctx) error {
 	if config.GenteelRole != "lightkeeper" && config.GenteelRole != "schildwaechter" {
 		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
 	}
+	gateErr := services.ConsultGates(ctx, "emission")
+	if gateErr != nil {
+		return gateErr
+	}
 	o11y.Logger.InfoContext(ctx, "Emanating local information with request headers", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 	headers := make(map[string]string)
 	c.Request().Header.VisitAll(func(key, value []byte) {
 		headers[string(key)] = string(value)
 	})
-	// gather Genteel environment variables
-	genteelenvs := make(map[string]string)
-	for _, e := range os.Environ() {
-		if strings.HasPrefix(strings.ToUpper(e), "GENTEEL_") {
-			pair := strings.SplitN(e, "=", 2)
-			if len(pair) == 2 {
-				genteelenvs[pair[0]] = pair[1]
-			}
-		}
-	}
+	genteelenvs := genteelEnvironment()
 	// get calling card
 	scribeResponse, _ := services.FocusedScribe(ctx, slogfiber.GetRequestIDFromContext(c.Context()))
 	tmpl, err := template.New("callingCardText").Parse("»{{ .Salutation }}« 👩🏻 {{ .Attendant }} 💌 Sincerely, {{ .Signature }}\n✉️ Card version {{ .CardVersion }} 🙋 {{ .Identifier }}")
@@ -180,6 +269,7 @@ func handleEmission(c *fiber.Ctx) error {
 	// respond with appropriate mimetype
 	offer := c.Accepts(fiber.MIMETextPlain, fiber.MIMETextHTML, fiber.MIMEApplicationJSON)
 	o11y.Logger.DebugContext(ctx, "Offer: "+offer)
+	o11y.RecordDelivery(ctx, "callingcard")
 	if offer == "text/html" {
 		c.Set("Content-type", "text/html")
 		return templates.HtmlCallingCard(scribeResponse).Render(c.Context(), c.Response().BodyWriter())
@@ -190,15 +280,27 @@ func handleEmission(c *fiber.Ctx) error {
 	return tmpl.ExecuteTemplate(c.Response().BodyWriter(), "callingCardText", scribeResponse)
 }
 
+// genteelEnvironment gathers the Genteel environment variables
+func genteelEnvironment() map[string]string {
+	genteelenvs := make(map[string]string)
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(strings.ToUpper(e), "GENTEEL_") {
+			pair := strings.SplitN(e, "=", 2)
+			if len(pair) == 2 {
+				genteelenvs[pair[0]] = pair[1]
+			}
+		}
+	}
+	return genteelenvs
+}
+
 func handleCalamity(c *fiber.Ctx) error {
 	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "CalamityEndpoint")
 	span.SetAttributes(attribute.String("RequestID", slogfiber.GetRequestIDFromContext(c.Context())))
 	defer span.End()
 	// the binary shall usually only serve a single purpose
 	if config.GenteelRole == "agitator" {
-		// this is a bit brutal
-		o11y.Logger.ErrorContext(ctx, "Disrupt!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
-		os.Exit(133)
+		return handleAgitation(ctx, c, span)
 	}
 	if config.GenteelRole != "lightkeeper" && config.GenteelRole != "schildwaechter" {
 		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
@@ -207,3 +309,100 @@ func handleCalamity(c *fiber.Ctx) error {
 	o11y.Logger.ErrorContext(ctx, "Calamity has been invoked!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Oh, no! A most dreadful calamity has occurred! 💥"})
 }
+
+// handleAgitation brings about the calamity asked for with ?mode=, by default we exit
+func handleAgitation(ctx context.Context, c *fiber.Ctx, span trace.Span) error {
+	// fiber reuses the query's memory, we may hold on to it
+	mode := strings.Clone(c.Query("mode", "exit"))
+	span.SetAttributes(attribute.String("genteel.calamity", mode))
+	switch mode {
+	case "exit":
+		// this is a bit brutal
+		o11y.Logger.ErrorContext(ctx, "Disrupt!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		os.Exit(133)
+	case "panic":
+		// for recover to catch
+		o11y.Logger.ErrorContext(ctx, "Panic!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		panic("a most dreadful calamity has occurred 💥")
+	case "slowloris":
+		delay, err := time.ParseDuration(c.Query("delay", "1s"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid delay")
+		}
+		o11y.Logger.WarnContext(ctx, "Answering at a snail's pace 🐌", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		c.Locals("streamed", true)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			for _, letter := range []byte("Oh, no! A most dreadful calamity has occurred!\n") {
+				if w.WriteByte(letter) != nil || w.Flush() != nil {
+					// they gave up on us
+					return
+				}
+				time.Sleep(delay)
+			}
+		})
+		return nil
+	}
+
+	calamity, err := agitator.CalamityFromMap(map[string]string{
+		"mode":     mode,
+		"rate":     c.Query("rate"),
+		"cores":    c.Query("cores"),
+		"duration": c.Query("duration"),
+	})
+	if err == nil {
+		err = agitator.Unleash(calamity)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	span.AddEvent("Calamity unleashed")
+	return c.Status(http.StatusAccepted).JSON(agitator.Running())
+}
+
+func handleMesh(c *fiber.Ctx) error {
+	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "MeshEndpoint")
+	span.SetAttributes(attribute.String("RequestID", slogfiber.GetRequestIDFromContext(c.Context())))
+	defer span.End()
+
+	gateErr := services.ConsultGates(ctx, "mesh")
+	if gateErr != nil {
+		return gateErr
+	}
+
+	hops, _ := strconv.Atoi(c.Get(services.HopHeader))
+	meshReport, err := services.TrainConductor(ctx, c.Params("endpoint"), hops)
+	if err != nil {
+		o11y.Logger.DebugContext(ctx, "Mesh call failed: "+err.Error())
+	}
+	// always hand back the report, it shows where things went wrong
+	return c.Status(meshReport.StatusCode).JSON(meshReport)
+}
+
+func handleRelay(c *fiber.Ctx) error {
+	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "RelayEndpoint")
+	span.SetAttributes(attribute.String("RequestID", slogfiber.GetRequestIDFromContext(c.Context())))
+	defer span.End()
+
+	// pass on what the upstream needs to answer properly
+	header := make(http.Header)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+		if strings.EqualFold(name, fiber.HeaderAccept) || strings.HasPrefix(strings.ToLower(name), "x-genteel-") {
+			header.Add(name, string(value))
+		}
+	})
+	header.Set(fiber.HeaderXRequestID, slogfiber.GetRequestIDFromContext(c.Context()))
+
+	relayResponse, err := services.PneumaticRelay(ctx, c.Path(), header)
+	if err != nil {
+		return err
+	}
+
+	// hand back whatever came through the tube
+	c.Set(services.RelayHeader, relayResponse.Upstream)
+	if relayResponse.ContentType != "" {
+		c.Set(fiber.HeaderContentType, relayResponse.ContentType)
+	}
+	return c.Status(relayResponse.StatusCode).Send(relayResponse.Body)
+}
@@ -0,0 +1,66 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"github.com/gofiber/fiber/v2"
+	slogfiber "github.com/samber/slog-fiber"
+)
+
+func TestSlowlorisTrickles(t *testing.T) {
+	role := config.GenteelRole
+	t.Cleanup(func() { config.GenteelRole = role })
+	config.GenteelRole = "agitator"
+	if o11y.Logger == nil {
+		o11y.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	// logged just like the beacon does
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(slogfiber.NewWithConfig(o11y.Logger, slogfiber.Config{Filters: []slogfiber.Filter{Unstreamed}}))
+	app.Get("/calamity", handleCalamity)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	const delay = 20 * time.Millisecond
+	start := time.Now()
+	resp, err := http.Get("http://" + listener.Addr().String() + "/calamity?mode=slowloris&delay=" + delay.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := resp.Body.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	firstByte := time.Since(start)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := time.Since(start)
+
+	// every letter but the first is held up by the delay
+	if full := time.Duration(len(body)) * delay; total < full {
+		t.Fatalf("answered within %s, want at least %s", total, full)
+	}
+	if firstByte > total/2 {
+		t.Errorf("first byte after %s of %s, want it trickled", firstByte, total)
+	}
+}
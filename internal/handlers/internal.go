@@ -0,0 +1,201 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// draining on request via the admin API, which may be undone, unlike draining on shutdown
+var adminDraining atomic.Bool
+
+// Draining tells whether the beacon reports not ready, on shutdown or on request
+func Draining(shutdown *atomic.Bool) bool {
+	return shutdown.Load() || adminDraining.Load()
+}
+
+// RegisterInternalRoutes adds the log levels and the admin API on the internal port, next to health and metrics.
+// Without GENTEEL_ADMIN_TOKEN, there is no admin API and anyone may change the log levels. Draining makes the beacon report not ready.
+func RegisterInternalRoutes(appInt *fiber.App, draining *atomic.Bool) {
+	appInt.Get("/loglevel", func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(o11y.LogLevels())
+	})
+
+	adminToken := config.GetEnv("GENTEEL_ADMIN_TOKEN", "")
+	// with a token, changing the levels takes it too
+	appInt.Put("/loglevel", func(c *fiber.Ctx) error {
+		if adminToken == "" {
+			return c.Next()
+		}
+		return adminAuth(c, adminToken)
+	}, func(c *fiber.Ctx) error {
+		return handleSetLogLevel(c)
+	})
+
+	if adminToken == "" {
+		o11y.Logger.Info("No GENTEEL_ADMIN_TOKEN, the admin API is disabled")
+		return
+	}
+
+	admin := appInt.Group("/admin", func(c *fiber.Ctx) error {
+		return adminAuth(c, adminToken)
+	})
+
+	admin.Get("/config", func(c *fiber.Ctx) error {
+		return handleConfigDump(c, draining)
+	})
+
+	admin.Get("/resources", func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(resourceLevels())
+	})
+
+	admin.Put("/resources/:name", func(c *fiber.Ctx) error {
+		return handleSetResourceLevel(c)
+	})
+
+	admin.Post("/resources/:name/trip", func(c *fiber.Ctx) error {
+		return handleForceTrip(c)
+	})
+
+	admin.Get("/chaos", func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(config.ChaosState())
+	})
+
+	admin.Put("/chaos", func(c *fiber.Ctx) error {
+		return handleChaosOverride(c)
+	})
+
+	admin.Delete("/chaos", func(c *fiber.Ctx) error {
+		config.SetChaosOverride(nil)
+		o11y.Logger.Info("Chaos override removed, following the flags again")
+		return c.Status(http.StatusOK).JSON(config.ChaosState())
+	})
+
+	admin.Post("/drain", func(c *fiber.Ctx) error {
+		adminDraining.Store(true)
+		o11y.Logger.Warn("Draining on request, reporting not ready 🚿")
+		return c.Status(http.StatusOK).JSON(fiber.Map{"draining": true})
+	})
+
+	admin.Delete("/drain", func(c *fiber.Ctx) error {
+		adminDraining.Store(false)
+		if draining.Load() {
+			// shutting down, there's no way back
+			return c.Status(http.StatusOK).JSON(fiber.Map{"draining": true})
+		}
+		o11y.Logger.Info("Done draining, reporting ready again")
+		return c.Status(http.StatusOK).JSON(fiber.Map{"draining": false})
+	})
+}
+
+// adminAuth lets only those with the bearer token pass
+func adminAuth(c *fiber.Ctx, adminToken string) error {
+	expected := []byte("Bearer " + adminToken)
+	if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+		return fiber.NewError(fiber.StatusUnauthorized, "Who goes there?")
+	}
+	return c.Next()
+}
+
+// resourceLevels tells the current level of every resource
+func resourceLevels() map[string]int64 {
+	levels := make(map[string]int64)
+	for _, resource := range services.Resources() {
+		levels[resource.Name] = resource.Level()
+	}
+	return levels
+}
+
+// handleConfigDump shows the effective configuration, but no secrets
+func handleConfigDump(c *fiber.Ctx, draining *atomic.Bool) error {
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"name":           config.AppName,
+		"role":           config.GenteelRole,
+		"node":           config.NodeName,
+		"version":        config.BuildVersion,
+		"draining":       Draining(draining),
+		"relayUpstreams": config.RelayUpstreams,
+		"relayTimeout":   config.RelayTimeout.String(),
+		"relayMaxHops":   config.RelayMaxHops,
+		"courier":        config.Courier,
+		"breakers":       services.BreakerStates(),
+		"topology":       config.Topology,
+		"resources":      config.Resources(),
+		"resourceModels": config.ResourceModels(),
+		"resourceLevels": resourceLevels(),
+		"chaos":          config.ChaosState(),
+		"chaosHeaders":   config.ChaosHeaders,
+		"sampling":       o11y.CurrentSamplingPolicy(),
+		"logLevels":      o11y.LogLevels(),
+	})
+}
+
+// handleSetResourceLevel puts the resource at ?level=
+func handleSetResourceLevel(c *fiber.Ctx) error {
+	resource := services.GetResource(c.Params("name"))
+	if resource == nil {
+		return fiber.NewError(fiber.StatusNotFound, "No such resource")
+	}
+	level, err := strconv.ParseInt(c.Query("level"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid level")
+	}
+	resource.SetLevel(level)
+	o11y.Logger.Info("Resource level set", "resource", resource.Name, "level", resource.Level())
+	return c.Status(http.StatusOK).JSON(resourceLevels())
+}
+
+// handleForceTrip makes the resource's gate trip for the next ?count= requests, one by default
+func handleForceTrip(c *fiber.Ctx) error {
+	resource := services.GetResource(c.Params("name"))
+	if resource == nil {
+		return fiber.NewError(fiber.StatusNotFound, "No such resource")
+	}
+	count, err := strconv.ParseInt(c.Query("count", "1"), 10, 64)
+	if err != nil || count < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid count")
+	}
+	resource.ForceTrip(count)
+	o11y.Logger.Warn("Gate will trip", "gate", resource.GateName, "count", count)
+	return c.Status(http.StatusOK).JSON(fiber.Map{"gate": resource.GateName, "trips": count})
+}
+
+// handleChaosOverride replaces the chaos flags with the posted ChaosOverride
+func handleChaosOverride(c *fiber.Ctx) error {
+	var override config.ChaosOverride
+	if err := c.BodyParser(&override); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid chaos override: "+err.Error())
+	}
+	for gate, chance := range override.Gates {
+		if chance < 0 || chance > 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "Chance for "+gate+" must be between 0 and 1")
+		}
+	}
+	config.SetChaosOverride(&override)
+	o11y.Logger.Warn("Chaos overridden locally", "chaosMode", override.ChaosMode, "gates", override.Gates)
+	return c.Status(http.StatusOK).JSON(config.ChaosState())
+}
+
+// handleSetLogLevel changes the level of a component, or the general one without ?component=,
+// an empty ?level= goes back to the environment's
+func handleSetLogLevel(c *fiber.Ctx) error {
+	component := c.Query("component")
+	level := c.Query("level")
+	if err := o11y.SetLogLevel(component, level); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	o11y.Logger.Info("Log level changed", "component", component, "level", level)
+	return c.Status(http.StatusOK).JSON(o11y.LogLevels())
+}
@@ -0,0 +1,101 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/ledger"
+	"github.com/schildwaechter/genteelbeacon/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	slogfiber "github.com/samber/slog-fiber"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// how many telegrams are listed unless asked for more
+const defaultLedgerLimit = 100
+
+// handleLedger lists the telegrams sent, filtered by ?since=, ?clock= and ?status=, the newest first
+func handleLedger(c *fiber.Ctx) error {
+	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "LedgerEndpoint")
+	span.SetAttributes(attribute.String("RequestID", slogfiber.GetRequestIDFromContext(c.Context())))
+	defer span.End()
+
+	// only those writing telegrams keep a ledger
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
+	}
+	if !config.Ledgering() {
+		return fiber.NewError(fiber.StatusNotImplemented, "No ledger kept")
+	}
+
+	// fiber reuses the query's memory, the span holds on to it
+	filter := ledger.Filter{
+		Clock:  strings.Clone(c.Query("clock")),
+		Status: strings.Clone(c.Query("status")),
+		Limit:  c.QueryInt("limit", defaultLedgerLimit),
+	}
+	if filter.Status != "" && filter.Status != ledger.StatusSent && filter.Status != ledger.StatusFailed && filter.Status != ledger.StatusAbandoned {
+		return fiber.NewError(fiber.StatusBadRequest, "Status must be sent, failed or abandoned")
+	}
+	if filter.Limit < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid limit")
+	}
+	if since := c.Query("since"); since != "" {
+		// a point in time or how far back
+		var err error
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			ago, agoErr := time.ParseDuration(since)
+			if agoErr != nil || ago < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "Since must be a RFC 3339 time or a duration")
+			}
+			filter.Since = time.Now().Add(-ago)
+		}
+	}
+	span.SetAttributes(
+		attribute.String("genteel.ledger.since", filter.Since.Format(time.RFC3339)),
+		attribute.String("genteel.ledger.clock", filter.Clock),
+		attribute.String("genteel.ledger.status", filter.Status),
+	)
+
+	entries, err := services.ListTelegrams(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(entries)
+}
+
+// handleLedgerEntry looks up the telegram with the identifier in the ledger
+func handleLedgerEntry(c *fiber.Ctx) error {
+	ctx, span := otel.Tracer(config.AppName).Start(c.UserContext(), "LedgerEntryEndpoint")
+	span.SetAttributes(attribute.String("RequestID", slogfiber.GetRequestIDFromContext(c.Context())))
+	defer span.End()
+
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
+	}
+	if !config.Ledgering() {
+		return fiber.NewError(fiber.StatusNotImplemented, "No ledger kept")
+	}
+
+	identifier := strings.Clone(c.Params("identifier"))
+	span.SetAttributes(attribute.String("genteel.ledger.identifier", identifier))
+	entry, err := services.LookupTelegram(ctx, identifier)
+	if errors.Is(err, ledger.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "No such telegram in the ledger")
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(entry)
+}
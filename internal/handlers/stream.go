@@ -0,0 +1,166 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"github.com/gofiber/fiber/v2"
+	slogfiber "github.com/samber/slog-fiber"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// we don't let anyone ask faster than this
+const minStreamInterval = 100 * time.Millisecond
+
+var (
+	// closed on shutdown, so open streams don't hold up draining
+	streamsDone  = make(chan struct{})
+	closeStreams sync.Once
+)
+
+// CloseStreams ends all open streams, the clients may reconnect elsewhere
+func CloseStreams() {
+	closeStreams.Do(func() {
+		close(streamsDone)
+	})
+}
+
+// Unstreamed tells the request logging to leave alone streamed answers,
+// logging their length would read the whole stream before it is sent
+func Unstreamed(c *fiber.Ctx) bool {
+	streamed, _ := c.Locals("streamed").(bool)
+	return !streamed
+}
+
+// handleTelegramStream pushes a telegram as server-sent event every ?interval= until the client leaves,
+// or ?count= telegrams have been sent
+func handleTelegramStream(c *fiber.Ctx) error {
+	// the writer runs after the handler returned, when the fiber context is gone
+	requestID := slogfiber.GetRequestIDFromContext(c.Context())
+
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
+	}
+
+	interval := config.StreamInterval
+	if c.Query("interval") != "" {
+		var err error
+		interval, err = time.ParseDuration(c.Query("interval"))
+		if err != nil || interval < minStreamInterval {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid interval")
+		}
+	}
+	count := c.QueryInt("count", 0)
+	if count < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid count")
+	}
+
+	// the stream's span lasts as long as the client listens, every telegram gets its own trace linked to it
+	// the request's context is done once the handler returned
+	ctx, span := otel.Tracer(config.AppName).Start(context.WithoutCancel(c.UserContext()), "TelegramStream")
+	span.SetAttributes(attribute.String("RequestID", requestID), attribute.String("genteel.stream.interval", interval.String()))
+	o11y.Logger.InfoContext(ctx, "Opening telegram stream every "+interval.String(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// proxies shall pass on every event right away
+	c.Set("X-Accel-Buffering", "no")
+
+	o11y.StreamOpened(ctx, "sse")
+	opened := time.Now()
+	c.Locals("streamed", true)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		delivered := 0
+		defer func() {
+			span.SetAttributes(attribute.Int("genteel.stream.delivered", delivered))
+			o11y.StreamClosed(ctx, "sse", time.Since(opened))
+			o11y.Logger.InfoContext(ctx, "Closed telegram stream after "+strconv.Itoa(delivered)+" telegrams", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+			span.End()
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for seq := 1; ; seq++ {
+			ok, err := streamTelegram(ctx, w, requestID, seq)
+			if err != nil {
+				// they gave up on us
+				span.AddEvent("Client gone")
+				return
+			}
+			if ok {
+				delivered++
+			}
+			if count > 0 && seq >= count {
+				span.AddEvent("Stream complete")
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-streamsDone:
+				span.AddEvent("Stream closed for shutdown")
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// streamTelegram writes a telegram, or why there is none, as event to the stream,
+// every telegram is traced on its own and linked to the stream
+func streamTelegram(streamCtx context.Context, w *bufio.Writer, requestID string, seq int) (bool, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(streamCtx, "StreamedTelegram",
+		trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(streamCtx)))
+	defer span.End()
+	eventID := requestID + "-" + strconv.Itoa(seq)
+	span.SetAttributes(attribute.String("RequestID", requestID), attribute.Int("genteel.stream.seq", seq))
+
+	event := "telegram"
+	var data []byte
+	telegram, err := writeTelegram(ctx, eventID, "")
+	if err != nil {
+		// the stream goes on, maybe the next one makes it
+		event = "error"
+		data = errorReport(span, err)
+	} else {
+		o11y.RecordDelivery(ctx, "telegram")
+		data, _ = json.Marshal(telegram)
+	}
+
+	if _, writeErr := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventID, event, data); writeErr != nil {
+		return false, writeErr
+	}
+	return err == nil, w.Flush()
+}
+
+// errorReport records the error on the span and tells it with its status, for messages that can't fail the request
+func errorReport(span trace.Span, err error) []byte {
+	status := fiber.StatusInternalServerError
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	report, _ := json.Marshal(fiber.Map{"status": status, "message": err.Error()})
+	return report
+}
@@ -0,0 +1,145 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/services"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	slogfiber "github.com/samber/slog-fiber"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// we transcribe telegrams, not novels
+const maxTelegraphMessage = 1024
+
+// telegraphUpgrade only lets telegraph lines through, keeping the handshake's context for the line
+func telegraphUpgrade(c *fiber.Ctx) error {
+	// the binary shall usually only serve a single purpose
+	if config.GenteelRole != "telegraphist" && config.GenteelRole != "schildwaechter" {
+		return fiber.NewError(fiber.StatusBadRequest, "Not my job!")
+	}
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	// the request's context is done once upgraded
+	c.Locals("lineContext", context.WithoutCancel(c.UserContext()))
+	c.Locals("lineRequestID", slogfiber.GetRequestIDFromContext(c.Context()))
+	return c.Next()
+}
+
+// handleTelegraph transcribes every message coming down the line and sends the telegram back,
+// one message after the other, so a sender waiting for ink has to wait for us
+func handleTelegraph(conn *websocket.Conn) {
+	requestID, _ := conn.Locals("lineRequestID").(string)
+	lineCtx, ok := conn.Locals("lineContext").(context.Context)
+	if !ok {
+		lineCtx = context.Background()
+	}
+
+	// the line's span lasts as long as the line is open, every message gets its own span linked to it
+	ctx, span := otel.Tracer(config.AppName).Start(lineCtx, "TelegraphLine")
+	span.SetAttributes(attribute.String("RequestID", requestID))
+	o11y.Logger.InfoContext(ctx, "Telegraph line open", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	o11y.StreamOpened(ctx, "websocket")
+	opened := time.Now()
+	delivered := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("genteel.telegraph.delivered", delivered))
+		o11y.StreamClosed(ctx, "websocket", time.Since(opened))
+		o11y.Logger.InfoContext(ctx, "Telegraph line closed after "+strconv.Itoa(delivered)+" telegrams", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		span.End()
+	}()
+
+	conn.SetReadLimit(maxTelegraphMessage)
+	// hang up on shutdown, the sender may call again elsewhere
+	hungUp := make(chan struct{})
+	defer close(hungUp)
+	go func() {
+		select {
+		case <-streamsDone:
+			span.AddEvent("Line closed for shutdown")
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "closing down"), time.Now().Add(time.Second))
+			_ = conn.Close()
+		case <-hungUp:
+		}
+	}()
+
+	for seq := 1; ; seq++ {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				span.AddEvent("Line closed")
+			} else {
+				span.AddEvent("Line dropped", trace.WithAttributes(attribute.String("error", err.Error())))
+			}
+			return
+		}
+		var reply []byte
+		if messageType != websocket.TextMessage {
+			reply, _ = json.Marshal(fiber.Map{"status": fiber.StatusBadRequest, "message": "text only, please"})
+		} else {
+			var transcribed bool
+			reply, transcribed = transcribeMessage(ctx, payload, requestID, seq)
+			if transcribed {
+				delivered++
+			}
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, reply); err != nil {
+			span.AddEvent("Line dropped", trace.WithAttributes(attribute.String("error", err.Error())))
+			return
+		}
+	}
+}
+
+// transcribeMessage has the clerk transcribe a message from the line, as part of the sender's trace
+// if it sent one, and linked to the line
+func transcribeMessage(lineCtx context.Context, payload []byte, requestID string, seq int) ([]byte, bool) {
+	var message types.TelegraphMessage
+	if json.Unmarshal(payload, &message) != nil {
+		// just the words, without an envelope
+		message = types.TelegraphMessage{Message: string(payload)}
+	}
+	senderCtx := otel.GetTextMapPropagator().Extract(lineCtx, propagation.MapCarrier(message.Carrier))
+	spanOptions := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindServer), trace.WithLinks(trace.LinkFromContext(lineCtx))}
+	if !trace.SpanContextFromContext(senderCtx).IsRemote() {
+		// not the line's trace, it may go on for hours
+		spanOptions = append(spanOptions, trace.WithNewRoot())
+	}
+	ctx, span := otel.Tracer(config.AppName).Start(senderCtx, "TelegraphMessage", spanOptions...)
+	defer span.End()
+	span.SetAttributes(attribute.String("RequestID", requestID), attribute.Int("genteel.telegraph.seq", seq))
+
+	words := strings.TrimSpace(message.Message)
+	if words == "" {
+		return errorReport(span, fiber.NewError(fiber.StatusBadRequest, "nothing to transcribe")), false
+	}
+	// no more messages are read while we wait for the ink to recover
+	if !services.AwaitGates(ctx, "telegram", config.TelegraphStall) {
+		return errorReport(span, fiber.NewError(fiber.StatusTooManyRequests, "still waiting for ink, try again later")), false
+	}
+	telegram, err := writeTelegram(ctx, requestID+"-"+strconv.Itoa(seq), words)
+	if err != nil {
+		return errorReport(span, err), false
+	}
+	o11y.RecordDelivery(ctx, "telegram")
+	reply, _ := json.Marshal(telegram)
+	return reply, true
+}
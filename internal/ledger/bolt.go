@@ -0,0 +1,146 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ledger
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// the entries by sequence and the sequences by identifier
+var (
+	telegramsBucket   = []byte("telegrams")
+	identifiersBucket = []byte("identifiers")
+)
+
+// boltStore keeps the entries in a bbolt database file, which only one beacon may open
+type boltStore struct {
+	db   *bolt.DB
+	size uint64
+}
+
+func newBoltStore(path string, size int) (*boltStore, error) {
+	// don't wait forever for another beacon to let go of the file
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(telegramsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(identifiersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db, size: uint64(size)}, nil
+}
+
+// sequenceKey sorts the keys in the order the entries were recorded
+func sequenceKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, sequence)
+}
+
+func (s *boltStore) Record(entry types.LedgerEntry) error {
+	// only one transaction writes at a time
+	return s.db.Update(func(tx *bolt.Tx) error {
+		entry.Recorded = time.Now()
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		telegrams := tx.Bucket(telegramsBucket)
+		identifiers := tx.Bucket(identifiersBucket)
+		sequence, err := telegrams.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := sequenceKey(sequence)
+		if err := telegrams.Put(key, value); err != nil {
+			return err
+		}
+		if err := identifiers.Put([]byte(entry.Identifier), key); err != nil {
+			return err
+		}
+		if sequence <= s.size {
+			return nil
+		}
+		// forget the oldest, unless the identifier was used again since
+		cursor := telegrams.Cursor()
+		oldest := sequenceKey(sequence - s.size)
+		for k, v := cursor.First(); k != nil && string(k) <= string(oldest); k, v = cursor.First() {
+			var forgotten types.LedgerEntry
+			if json.Unmarshal(v, &forgotten) == nil && string(identifiers.Get([]byte(forgotten.Identifier))) == string(k) {
+				if err := identifiers.Delete([]byte(forgotten.Identifier)); err != nil {
+					return err
+				}
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Lookup(identifier string) (types.LedgerEntry, error) {
+	var entry types.LedgerEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(identifiersBucket).Get([]byte(identifier))
+		if key == nil {
+			return ErrNotFound
+		}
+		value := tx.Bucket(telegramsBucket).Get(key)
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &entry)
+	})
+	return entry, err
+}
+
+func (s *boltStore) List(filter Filter) ([]types.LedgerEntry, error) {
+	entries := []types.LedgerEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(telegramsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var entry types.LedgerEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			// the older ones were all recorded before
+			if entry.Recorded.Before(filter.Since) {
+				return nil
+			}
+			if filter.Matches(entry) {
+				entries = append(entries, entry)
+				if filter.Limit > 0 && len(entries) >= filter.Limit {
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *boltStore) System() string {
+	return config.LedgerBolt
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
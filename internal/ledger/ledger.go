@@ -0,0 +1,65 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ledger records the telegrams sent, in memory or in a database file.
+package ledger
+
+import (
+	"errors"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+)
+
+// the outcomes of the telegrams recorded
+const (
+	StatusSent      = "sent"
+	StatusFailed    = "failed"
+	StatusAbandoned = "abandoned" // given up before it was done, e.g. on shutdown
+)
+
+// ErrNotFound is returned when the ledger has no telegram with the identifier
+var ErrNotFound = errors.New("no such telegram in the ledger")
+
+// Filter selects the telegrams to list, empty fields match everything
+type Filter struct {
+	Since  time.Time // recorded at or after
+	Clock  string    // the clock reference
+	Status string    // sent or failed
+	Limit  int       // how many at most, the newest first
+}
+
+// Matches tells whether the entry is one of the filter's
+func (f Filter) Matches(entry types.LedgerEntry) bool {
+	return (f.Clock == "" || entry.ClockReference == f.Clock) &&
+		(f.Status == "" || entry.Status == f.Status) &&
+		!entry.Recorded.Before(f.Since)
+}
+
+// Store keeps the entries of the ledger, forgetting the oldest beyond its size
+type Store interface {
+	// Record adds the entry, stamped as recorded in the order the entries are added, which List relies on
+	Record(entry types.LedgerEntry) error
+	// Lookup finds the latest entry with the identifier, ErrNotFound if there is none
+	Lookup(identifier string) (types.LedgerEntry, error)
+	// List returns the entries matching the filter, the newest first
+	List(filter Filter) ([]types.LedgerEntry, error)
+	// System names the store for the spans, e.g. memory or bolt
+	System() string
+	// Close lets go of the store, entries in memory are lost
+	Close() error
+}
+
+// New sets up the store of the configured kind
+func New() (Store, error) {
+	switch config.Ledger.Kind {
+	case config.LedgerMemory:
+		return newMemoryStore(config.Ledger.Size), nil
+	case config.LedgerBolt:
+		return newBoltStore(config.Ledger.Path, config.Ledger.Size)
+	}
+	return nil, errors.New("no ledger configured")
+}
@@ -0,0 +1,141 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ledger
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+)
+
+// both stores, each with room for size entries
+func testStores(t *testing.T, size int) map[string]Store {
+	bolt, err := newBoltStore(filepath.Join(t.TempDir(), "ledger.db"), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]Store{
+		"memory": newMemoryStore(size),
+		"bolt":   bolt,
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	tests := []struct {
+		name       string
+		recorded   []string // the identifiers, a message is numbered by its position
+		wantList   []string // the messages listed, the newest first
+		wantLookup map[string]string
+	}{
+		{
+			name:       "below the size",
+			recorded:   []string{"a", "b"},
+			wantList:   []string{"1", "0"},
+			wantLookup: map[string]string{"a": "0", "b": "1"},
+		},
+		{
+			name:       "oldest forgotten",
+			recorded:   []string{"a", "b", "c"},
+			wantList:   []string{"2", "1"},
+			wantLookup: map[string]string{"a": "", "b": "1", "c": "2"},
+		},
+		{
+			name:       "identifier used again survives the eviction of its first entry",
+			recorded:   []string{"a", "b", "a", "c"},
+			wantList:   []string{"3", "2"},
+			wantLookup: map[string]string{"a": "2", "b": "", "c": "3"},
+		},
+		{
+			name:       "latest of an identifier used again",
+			recorded:   []string{"a", "a"},
+			wantList:   []string{"1", "0"},
+			wantLookup: map[string]string{"a": "1"},
+		},
+	}
+	for _, tt := range tests {
+		for system, store := range testStores(t, 2) {
+			t.Run(system+"/"+tt.name, func(t *testing.T) {
+				for i, identifier := range tt.recorded {
+					entry := types.LedgerEntry{Identifier: identifier, Message: strconv.Itoa(i), Recorded: time.Now(), Status: StatusSent}
+					if err := store.Record(entry); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				entries, err := store.List(Filter{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var listed []string
+				for _, entry := range entries {
+					listed = append(listed, entry.Message)
+				}
+				if !slices.Equal(listed, tt.wantList) {
+					t.Errorf("listed %q, want %q", listed, tt.wantList)
+				}
+
+				for identifier, want := range tt.wantLookup {
+					entry, err := store.Lookup(identifier)
+					if want == "" {
+						if !errors.Is(err, ErrNotFound) {
+							t.Errorf("lookup of %s: got %q, %v, want it forgotten", identifier, entry.Message, err)
+						}
+						continue
+					}
+					if err != nil || entry.Message != want {
+						t.Errorf("lookup of %s: got %q, %v, want %q", identifier, entry.Message, err, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStoreRecordedInOrder(t *testing.T) {
+	for system, store := range testStores(t, 100) {
+		t.Run(system, func(t *testing.T) {
+			// written concurrently, each taking its time before it is recorded
+			var writers sync.WaitGroup
+			for i := range 50 {
+				writers.Go(func() {
+					entry := types.LedgerEntry{Identifier: strconv.Itoa(i), Recorded: time.Now().Add(-time.Duration(i) * time.Millisecond)}
+					if err := store.Record(entry); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+			writers.Wait()
+
+			entries, err := store.List(Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 50 {
+				t.Fatalf("listed %d entries, want 50", len(entries))
+			}
+			for i := 1; i < len(entries); i++ {
+				if entries[i].Recorded.After(entries[i-1].Recorded) {
+					t.Fatalf("entry %s recorded after the newer %s", entries[i].Identifier, entries[i-1].Identifier)
+				}
+			}
+			// nothing is skipped from the middle on
+			since, err := store.List(Filter{Since: entries[25].Recorded})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(since) < 26 {
+				t.Errorf("listed %d entries since the 26th newest, want at least 26", len(since))
+			}
+		})
+	}
+}
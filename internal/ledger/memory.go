@@ -0,0 +1,79 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ledger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+)
+
+// memoryStore keeps the entries in a ring buffer, they don't survive a restart
+type memoryStore struct {
+	mu      sync.RWMutex
+	entries []types.LedgerEntry
+	next    int // where the next entry goes
+	count   int // how many entries we have, up to the size
+}
+
+func newMemoryStore(size int) *memoryStore {
+	return &memoryStore{entries: make([]types.LedgerEntry, size)}
+}
+
+func (s *memoryStore) Record(entry types.LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Recorded = time.Now()
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	s.count = min(s.count+1, len(s.entries))
+	return nil
+}
+
+// newest walks the entries from the newest to the oldest until visit says stop
+func (s *memoryStore) newest(visit func(entry types.LedgerEntry) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := 1; i <= s.count; i++ {
+		if !visit(s.entries[(s.next-i+len(s.entries))%len(s.entries)]) {
+			return
+		}
+	}
+}
+
+func (s *memoryStore) Lookup(identifier string) (types.LedgerEntry, error) {
+	var found types.LedgerEntry
+	err := ErrNotFound
+	s.newest(func(entry types.LedgerEntry) bool {
+		if entry.Identifier == identifier {
+			found, err = entry, nil
+		}
+		return err != nil
+	})
+	return found, err
+}
+
+func (s *memoryStore) List(filter Filter) ([]types.LedgerEntry, error) {
+	entries := []types.LedgerEntry{}
+	s.newest(func(entry types.LedgerEntry) bool {
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+		// the older ones were all recorded before
+		return (filter.Limit <= 0 || len(entries) < filter.Limit) && !entry.Recorded.Before(filter.Since)
+	})
+	return entries, nil
+}
+
+func (s *memoryStore) System() string {
+	return config.LedgerMemory
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,264 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package loadgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type acceptWeight struct {
+	mimeType string
+	weight   int
+}
+
+type loadSettings struct {
+	targets       []string
+	rps           float64
+	concurrency   int
+	ramp          string
+	rampPeriod    time.Duration
+	accepts       []acceptWeight
+	burstInterval time.Duration
+	burstSize     int
+	timeout       time.Duration
+}
+
+type loadJob struct {
+	target string
+	accept string
+}
+
+// readSettings gets the load profile from GENTEEL_LOAD_* environment variables
+func readSettings() (loadSettings, error) {
+	var settings loadSettings
+	var err error
+
+	for target := range strings.SplitSeq(config.GetEnv("GENTEEL_LOAD_TARGETS", "http://localhost:1333/telegram"), ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			settings.targets = append(settings.targets, target)
+		}
+	}
+	if len(settings.targets) == 0 {
+		return settings, errors.New("no targets")
+	}
+	if settings.rps, err = strconv.ParseFloat(config.GetEnv("GENTEEL_LOAD_RPS", "5"), 64); err != nil || settings.rps <= 0 {
+		return settings, fmt.Errorf("GENTEEL_LOAD_RPS must be a positive number")
+	}
+	if settings.concurrency, err = strconv.Atoi(config.GetEnv("GENTEEL_LOAD_CONCURRENCY", "4")); err != nil || settings.concurrency <= 0 {
+		return settings, fmt.Errorf("GENTEEL_LOAD_CONCURRENCY must be a positive integer")
+	}
+	settings.ramp = config.GetEnv("GENTEEL_LOAD_RAMP", "constant")
+	if settings.ramp != "constant" && settings.ramp != "linear" && settings.ramp != "sine" && settings.ramp != "step" {
+		return settings, fmt.Errorf("unknown ramp %q", settings.ramp)
+	}
+	if settings.rampPeriod, err = time.ParseDuration(config.GetEnv("GENTEEL_LOAD_RAMP_PERIOD", "5m")); err != nil || settings.rampPeriod <= 0 {
+		return settings, fmt.Errorf("GENTEEL_LOAD_RAMP_PERIOD must be a positive duration")
+	}
+	for accept := range strings.SplitSeq(config.GetEnv("GENTEEL_LOAD_ACCEPT", "application/json=1,text/html=1,text/plain=1"), ",") {
+		mimeType, weight, found := strings.Cut(strings.TrimSpace(accept), "=")
+		weightValue := 1
+		if found {
+			if weightValue, err = strconv.Atoi(weight); err != nil || weightValue < 0 {
+				return settings, fmt.Errorf("invalid weight for %s", mimeType)
+			}
+		}
+		if mimeType != "" && weightValue > 0 {
+			settings.accepts = append(settings.accepts, acceptWeight{mimeType, weightValue})
+		}
+	}
+	if len(settings.accepts) == 0 {
+		return settings, errors.New("GENTEEL_LOAD_ACCEPT needs at least one type")
+	}
+	if settings.burstInterval, err = time.ParseDuration(config.GetEnv("GENTEEL_LOAD_BURST_INTERVAL", "0s")); err != nil {
+		return settings, fmt.Errorf("invalid GENTEEL_LOAD_BURST_INTERVAL: %w", err)
+	}
+	if settings.burstSize, err = strconv.Atoi(config.GetEnv("GENTEEL_LOAD_BURST_SIZE", "20")); err != nil {
+		return settings, fmt.Errorf("invalid GENTEEL_LOAD_BURST_SIZE: %w", err)
+	}
+	if settings.timeout, err = time.ParseDuration(config.GetEnv("GENTEEL_LOAD_TIMEOUT", "10s")); err != nil {
+		return settings, fmt.Errorf("invalid GENTEEL_LOAD_TIMEOUT: %w", err)
+	}
+	return settings, nil
+}
+
+// currentRate follows the ramp profile
+func (s loadSettings) currentRate(elapsed time.Duration) float64 {
+	progress := float64(elapsed) / float64(s.rampPeriod)
+	switch s.ramp {
+	case "linear":
+		// ramp up once, then stay
+		return s.rps * math.Min(1, progress)
+	case "sine":
+		// waves between nothing and the full rate
+		return s.rps * (0.5 - 0.5*math.Cos(2*math.Pi*progress))
+	case "step":
+		// climbing stairs in quarters, starting over at the top
+		return s.rps * float64(int(progress)%4+1) / 4
+	default:
+		return s.rps
+	}
+}
+
+// pickAccept chooses an Accept header according to the weights
+func (s loadSettings) pickAccept() string {
+	total := 0
+	for _, accept := range s.accepts {
+		total += accept.weight
+	}
+	choice := rand.IntN(total)
+	for _, accept := range s.accepts {
+		if choice < accept.weight {
+			return accept.mimeType
+		}
+		choice -= accept.weight
+	}
+	return s.accepts[0].mimeType
+}
+
+// fire sends a single request and records the outcome
+func fire(client *http.Client, job loadJob) {
+	// the transport adds the client span
+	ctx, span := otel.Tracer(config.AppName).Start(context.Background(), "LoadRequest")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("genteel.load.target", job.target),
+		attribute.String("genteel.load.accept", job.accept),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", job.target, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.RecordLoadError(ctx, job.target, "request")
+		return
+	}
+	req.Header.Set("Accept", job.accept)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		reason := "connection"
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			reason = "timeout"
+		}
+		o11y.RecordLoadRequest(ctx, job.target, 0, time.Since(start))
+		o11y.RecordLoadError(ctx, job.target, reason)
+		o11y.Logger.DebugContext(ctx, "Load request failed: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		return
+	}
+	// read everything, the duration includes the body
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	duration := time.Since(start)
+
+	o11y.RecordLoadRequest(ctx, job.target, resp.StatusCode, duration)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+		o11y.RecordLoadError(ctx, job.target, "http_5xx")
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		o11y.RecordLoadError(ctx, job.target, "http_4xx")
+	}
+}
+
+// RunLoadGenerator is what we run in loadgenerator mode, until the context is done
+func RunLoadGenerator(ctx context.Context) error {
+	settings, err := readSettings()
+	if err != nil {
+		o11y.Logger.Error("Invalid load settings: " + err.Error())
+		return err
+	}
+	o11y.Logger.Info(fmt.Sprintf("Generating %s load of up to %.1f rps with %d workers against %v", settings.ramp, settings.rps, settings.concurrency, settings.targets))
+
+	client := &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		Timeout:   settings.timeout,
+	}
+
+	// the workers take jobs as long as they can keep up
+	// requests in flight are finished before we return
+	var inFlight sync.WaitGroup
+	jobs := make(chan loadJob, settings.concurrency)
+	for range settings.concurrency {
+		inFlight.Go(func() {
+			for job := range jobs {
+				fire(client, job)
+			}
+		})
+	}
+	nextJob := func() loadJob {
+		return loadJob{
+			target: settings.targets[rand.IntN(len(settings.targets))],
+			accept: settings.pickAccept(),
+		}
+	}
+	dispatch := func() {
+		job := nextJob()
+		select {
+		case jobs <- job:
+		default:
+			// all workers busy, the target is slower than the load
+			o11y.RecordLoadError(context.Background(), job.target, "dropped")
+		}
+	}
+
+	start := time.Now()
+	lastBurst := start
+	for ctx.Err() == nil {
+		elapsed := time.Since(start)
+		if settings.burstInterval > 0 && time.Since(lastBurst) >= settings.burstInterval {
+			lastBurst = time.Now()
+			o11y.Logger.Debug(fmt.Sprintf("Bursting %d requests 💥", settings.burstSize))
+			// bursts don't wait for the workers
+			for range settings.burstSize {
+				job := nextJob()
+				inFlight.Go(func() { fire(client, job) })
+			}
+		}
+		rate := settings.currentRate(elapsed)
+		if rate <= 0.01 {
+			// nothing to do right now
+			sleep(ctx, 100*time.Millisecond)
+			continue
+		}
+		dispatch()
+		sleep(ctx, time.Duration(float64(time.Second)/rate))
+	}
+
+	o11y.Logger.Info("Stopping load generation, waiting for requests in flight")
+	close(jobs)
+	inFlight.Wait()
+	return nil
+}
+
+// sleep waits for the duration, unless the context is done first
+func sleep(ctx context.Context, duration time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+}
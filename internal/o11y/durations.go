@@ -0,0 +1,105 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the buckets cover the quick gates as well as the scribe's pen search
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 10}
+
+var (
+	requestDurationOtel metric.Float64Histogram
+	serviceDurationOtel metric.Float64Histogram
+	requestDurationProm *prometheus.HistogramVec
+	serviceDurationProm *prometheus.HistogramVec
+	durationAttribs     []attribute.KeyValue
+)
+
+// InitDurationMetrics sets up the latency histograms of endpoints and internal services in both OTEL and Prometheus
+func InitDurationMetrics(appName string, commonAttribs []attribute.KeyValue) {
+	meter := otel.GetMeterProvider().Meter(appName)
+	durationAttribs = slices.Clip(commonAttribs)
+
+	// OTEL attaches exemplars of sampled traces on its own
+	requestDurationOtel, _ = meter.Float64Histogram(
+		"genteelbeacon_endpoint_duration",
+		metric.WithDescription("The duration of requests per endpoint"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	serviceDurationOtel, _ = meter.Float64Histogram(
+		"genteelbeacon_service_duration",
+		metric.WithDescription("The duration of the internal services"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+
+	promLabels := make(prometheus.Labels)
+	for _, attr := range commonAttribs {
+		promLabels[string(attr.Key)] = attr.Value.AsString()
+	}
+	requestDurationProm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "genteelbeacon_endpoint_duration_seconds",
+		Help:        "The duration of requests per endpoint",
+		ConstLabels: promLabels,
+		Buckets:     durationBuckets,
+	}, []string{"endpoint", "method", "status"})
+	serviceDurationProm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "genteelbeacon_service_duration_seconds",
+		Help:        "The duration of the internal services",
+		ConstLabels: promLabels,
+		Buckets:     durationBuckets,
+	}, []string{"service", "outcome"})
+}
+
+// observeWithTrace adds the trace as exemplar, if it is sampled and thus can be found
+func observeWithTrace(ctx context.Context, observer prometheus.Observer, seconds float64) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	exemplarObserver, ok := observer.(prometheus.ExemplarObserver)
+	if !ok || !spanContext.IsSampled() {
+		observer.Observe(seconds)
+		return
+	}
+	exemplarObserver.ObserveWithExemplar(seconds, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+}
+
+// RecordEndpointDuration records how long a request to the endpoint (the route) took
+func RecordEndpointDuration(ctx context.Context, endpoint string, method string, status int, duration time.Duration) {
+	// the metrics may not be set up, e.g. in tools using the services
+	if requestDurationProm == nil {
+		return
+	}
+	statusLabel := strconv.Itoa(status)
+	requestDurationOtel.Record(ctx, duration.Seconds(), metric.WithAttributes(append(durationAttribs,
+		attribute.String("endpoint", endpoint), attribute.String("method", method), attribute.String("status", statusLabel))...))
+	observeWithTrace(ctx, requestDurationProm.WithLabelValues(endpoint, method, statusLabel), duration.Seconds())
+}
+
+// RecordServiceDuration records how long an internal service, e.g. DiligentClerk or InkWell, took
+func RecordServiceDuration(ctx context.Context, service string, duration time.Duration, err error) {
+	if serviceDurationProm == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	serviceDurationOtel.Record(ctx, duration.Seconds(), metric.WithAttributes(append(durationAttribs,
+		attribute.String("service", service), attribute.String("outcome", outcome))...))
+	observeWithTrace(ctx, serviceDurationProm.WithLabelValues(service, outcome), duration.Seconds())
+}
@@ -0,0 +1,130 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the signals we send via OTLP
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+)
+
+// the OTLP protocols we support
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// RetryConfig says how often and how long a failed export is retried
+type RetryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// OtlpExporter describes how one signal is sent.
+// Endpoint, TLS, headers, compression and timeout come from the standard OTEL_EXPORTER_OTLP_* variables,
+// which the exporters read themselves, unless a legacy plain host:port Endpoint is set.
+type OtlpExporter struct {
+	Signal   string
+	Protocol string
+	Endpoint string
+	Retry    RetryConfig
+}
+
+// String tells where the signal goes, for the logs
+func (e OtlpExporter) String() string {
+	target := e.Endpoint
+	if target == "" {
+		target = lookupOtlpEnv(e.Signal, "ENDPOINT")
+	}
+	return e.Signal + " via " + e.Protocol + " to " + target
+}
+
+// lookupOtlpEnv prefers the signal specific variable over the general one
+func lookupOtlpEnv(signal string, name string) string {
+	if value, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_" + name); ok {
+		return value
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_" + name)
+}
+
+// durationEnv reads a duration with a default
+func durationEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return duration, nil
+}
+
+// retryFromEnv reads the retry settings, the defaults are those of the OTEL exporters
+func retryFromEnv() (RetryConfig, error) {
+	retry := RetryConfig{Enabled: true}
+	var err error
+	if value, ok := os.LookupEnv("GENTEEL_OTLP_RETRY"); ok {
+		if retry.Enabled, err = strconv.ParseBool(value); err != nil {
+			return retry, fmt.Errorf("invalid GENTEEL_OTLP_RETRY: %w", err)
+		}
+	}
+	if retry.InitialInterval, err = durationEnv("GENTEEL_OTLP_RETRY_INITIAL_INTERVAL", 5*time.Second); err != nil {
+		return retry, err
+	}
+	if retry.MaxInterval, err = durationEnv("GENTEEL_OTLP_RETRY_MAX_INTERVAL", 30*time.Second); err != nil {
+		return retry, err
+	}
+	if retry.MaxElapsedTime, err = durationEnv("GENTEEL_OTLP_RETRY_MAX_ELAPSED_TIME", time.Minute); err != nil {
+		return retry, err
+	}
+	return retry, nil
+}
+
+// OtlpExporterFromEnv tells how to send the signal, nil if it is not sent at all.
+// The legacy OTLPHTTP_TRACES_ENDPOINT (traces only) and OTLPHTTP_ENDPOINT take precedence
+// over the standard OTEL_EXPORTER_OTLP_* variables.
+func OtlpExporterFromEnv(signal string) (*OtlpExporter, error) {
+	retry, err := retryFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if tracesEndpoint, ok := os.LookupEnv("OTLPHTTP_TRACES_ENDPOINT"); ok {
+		if signal != SignalTraces {
+			return nil, nil
+		}
+		return &OtlpExporter{Signal: signal, Protocol: ProtocolHTTP, Endpoint: tracesEndpoint, Retry: retry}, nil
+	}
+	if endpoint, ok := os.LookupEnv("OTLPHTTP_ENDPOINT"); ok {
+		return &OtlpExporter{Signal: signal, Protocol: ProtocolHTTP, Endpoint: endpoint, Retry: retry}, nil
+	}
+
+	// the standard way, e.g. OTEL_TRACES_EXPORTER=none switches a signal off
+	if lookupOtlpEnv(signal, "ENDPOINT") == "" || os.Getenv("OTEL_"+strings.ToUpper(signal)+"_EXPORTER") == "none" {
+		return nil, nil
+	}
+	protocol := lookupOtlpEnv(signal, "PROTOCOL")
+	switch protocol {
+	case "":
+		protocol = ProtocolHTTP
+	case ProtocolGRPC, ProtocolHTTP:
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q for %s", protocol, signal)
+	}
+	return &OtlpExporter{Signal: signal, Protocol: protocol, Retry: retry}, nil
+}
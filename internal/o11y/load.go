@@ -0,0 +1,87 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	loadDurationOtel metric.Float64Histogram
+	loadRequestsOtel metric.Int64Counter
+	loadErrorsOtel   metric.Int64Counter
+	loadDurationProm *prometheus.HistogramVec
+	loadRequestsProm *prometheus.CounterVec
+	loadErrorsProm   *prometheus.CounterVec
+	loadAttribs      []attribute.KeyValue
+)
+
+// InitLoadMetrics sets up the client-side metrics of the load generator in both OTEL and Prometheus
+func InitLoadMetrics(appName string, commonAttribs []attribute.KeyValue) {
+	meter := otel.GetMeterProvider().Meter(appName)
+	// clipped, so appending never shares the array
+	loadAttribs = slices.Clip(commonAttribs)
+
+	loadDurationOtel, _ = meter.Float64Histogram(
+		"genteelbeacon_load_duration",
+		metric.WithDescription("The duration of requests sent by the load generator"),
+		metric.WithUnit("s"),
+	)
+	loadRequestsOtel, _ = meter.Int64Counter(
+		"genteelbeacon_load_requests",
+		metric.WithDescription("The requests sent by the load generator"),
+	)
+	loadErrorsOtel, _ = meter.Int64Counter(
+		"genteelbeacon_load_errors",
+		metric.WithDescription("The requests of the load generator that failed or were dropped"),
+	)
+
+	promLabels := make(prometheus.Labels)
+	for _, attr := range commonAttribs {
+		promLabels[string(attr.Key)] = attr.Value.AsString()
+	}
+	loadDurationProm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "genteelbeacon_load_duration_seconds",
+		Help:        "The duration of requests sent by the load generator",
+		ConstLabels: promLabels,
+		Buckets:     prometheus.DefBuckets,
+	}, []string{"target", "status"})
+	loadRequestsProm = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:        "genteelbeacon_load_requests_total",
+		Help:        "The requests sent by the load generator",
+		ConstLabels: promLabels,
+	}, []string{"target", "status"})
+	loadErrorsProm = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:        "genteelbeacon_load_errors_total",
+		Help:        "The requests of the load generator that failed or were dropped",
+		ConstLabels: promLabels,
+	}, []string{"target", "reason"})
+}
+
+// RecordLoadRequest records a completed request, status 0 if there was no response
+func RecordLoadRequest(ctx context.Context, target string, status int, duration time.Duration) {
+	statusLabel := strconv.Itoa(status)
+	attribs := metric.WithAttributes(append(loadAttribs, attribute.String("target", target), attribute.String("status", statusLabel))...)
+	loadDurationOtel.Record(ctx, duration.Seconds(), attribs)
+	loadRequestsOtel.Add(ctx, 1, attribs)
+	loadDurationProm.WithLabelValues(target, statusLabel).Observe(duration.Seconds())
+	loadRequestsProm.WithLabelValues(target, statusLabel).Inc()
+}
+
+// RecordLoadError records a request that failed or was never sent, e.g. "timeout", "http_5xx" or "dropped"
+func RecordLoadError(ctx context.Context, target string, reason string) {
+	loadErrorsOtel.Add(ctx, 1, metric.WithAttributes(append(loadAttribs, attribute.String("target", target), attribute.String("reason", reason))...))
+	loadErrorsProm.WithLabelValues(target, reason).Inc()
+}
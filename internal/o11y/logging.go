@@ -7,8 +7,13 @@ package o11y
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	slogmulti "github.com/samber/slog-multi"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
@@ -17,22 +22,172 @@ import (
 
 var Logger *slog.Logger
 
+// the components with their own loggers and levels
+const (
+	ComponentClerk     = "clerk"
+	ComponentCourier   = "courier"
+	ComponentScribe    = "scribe"
+	ComponentResources = "resources"
+	ComponentGearsmith = "gearsmith"
+	ComponentLedger    = "ledger"
+)
+
+// components lists them all, others have no level to set
+var components = []string{ComponentClerk, ComponentCourier, ComponentScribe, ComponentResources, ComponentGearsmith, ComponentLedger}
+
+var (
+	// the handler all loggers share, before level filtering
+	logHandler slog.Handler
+	logLevel   componentLevel
+	// the levels from the environment, to go back to
+	envLogLevels     = make(map[string]slog.Level)
+	componentLoggers sync.Map
+	componentLevels  sync.Map
+)
+
+// componentLevel is the component's own level, or that of the fallback if it has none
+type componentLevel struct {
+	own      slog.LevelVar
+	set      atomic.Bool
+	fallback slog.Leveler
+}
+
+func (l *componentLevel) Level() slog.Level {
+	if !l.set.Load() && l.fallback != nil {
+		return l.fallback.Level()
+	}
+	return l.own.Level()
+}
+
+// levelHandler filters by a level that can change at any time
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.Handler.WithAttrs(attrs), h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.Handler.WithGroup(name), h.level}
+}
+
+// InitLogLevels reads LOG_LEVEL and LOG_LEVEL_<COMPONENT> for the components,
+// before anything may change them at runtime
+func InitLogLevels(jsonLogging bool) error {
+	// the text logs were always meant for debugging
+	defaultLevel := slog.LevelDebug
+	if jsonLogging {
+		defaultLevel = slog.LevelInfo
+	}
+	if err := defaultLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", defaultLevel.String()))); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	envLogLevels[""] = defaultLevel
+	logLevel.own.Set(defaultLevel)
+	logLevel.set.Store(true)
+
+	for _, component := range components {
+		level := getComponentLevel(component)
+		envName := "LOG_LEVEL_" + strings.ToUpper(component)
+		if value, ok := os.LookupEnv(envName); ok {
+			var envLevel slog.Level
+			if err := envLevel.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("invalid %s: %w", envName, err)
+			}
+			envLogLevels[component] = envLevel
+			level.own.Set(envLevel)
+			level.set.Store(true)
+		}
+	}
+	return nil
+}
+
+// CreateLogger sets up the logger with fanout to both stdout and OTEL, filtered by the levels
 func CreateLogger(appName string, jsonLogging bool) {
 	if jsonLogging {
-		Logger = slog.New(
-			slogmulti.Fanout(
-				otelslog.NewLogger(appName).Handler(),
-				slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{}),
-			),
+		logHandler = slogmulti.Fanout(
+			otelslog.NewLogger(appName).Handler(),
+			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
 		)
 	} else {
-		Logger = slog.New(
-			slogmulti.Fanout(
-				otelslog.NewLogger(appName).Handler(),
-				slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-			),
+		logHandler = slogmulti.Fanout(
+			otelslog.NewLogger(appName).Handler(),
+			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
 		)
 	}
+	Logger = slog.New(levelHandler{logHandler, &logLevel})
+}
+
+// getEnv reads a variable with a default, like config.GetEnv
+func getEnv(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func getComponentLevel(component string) *componentLevel {
+	level, _ := componentLevels.LoadOrStore(component, &componentLevel{fallback: &logLevel})
+	return level.(*componentLevel)
+}
+
+// Component returns the component's logger, which follows the general level unless it has its own
+func Component(component string) *slog.Logger {
+	if logger, ok := componentLoggers.Load(component); ok {
+		return logger.(*slog.Logger)
+	}
+	handler := logHandler
+	if handler == nil {
+		// not set up yet, e.g. in tools using the services
+		return slog.Default()
+	}
+	logger, _ := componentLoggers.LoadOrStore(component, slog.New(levelHandler{handler, getComponentLevel(component)}).With("component", component))
+	return logger.(*slog.Logger)
+}
+
+// SetLogLevel changes the level of the component, or the general one for "" or "default".
+// An empty level goes back to the one from the environment.
+func SetLogLevel(component string, level string) error {
+	if component == "default" {
+		component = ""
+	}
+	if component != "" && !slices.Contains(components, component) {
+		return fmt.Errorf("unknown component %q", component)
+	}
+	target := &logLevel
+	if component != "" {
+		target = getComponentLevel(component)
+	}
+	if level == "" {
+		envLevel, ok := envLogLevels[component]
+		target.own.Set(envLevel)
+		// components without their own level follow the general one again
+		target.set.Store(ok || component == "")
+		return nil
+	}
+	var newLevel slog.Level
+	if err := newLevel.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	target.own.Set(newLevel)
+	target.set.Store(true)
+	return nil
+}
+
+// LogLevels tells the general level ("default") and those of the components
+func LogLevels() map[string]string {
+	levels := map[string]string{"default": logLevel.Level().String()}
+	componentLevels.Range(func(component, level any) bool {
+		levels[component.(string)] = level.(*componentLevel).Level().String()
+		return true
+	})
+	return levels
 }
 
 func LoggerTraceAttr(ctx context.Context, span trace.Span) slog.Attr {
@@ -16,63 +16,52 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
-var (
-	GreaseBuildupGaugeProm prometheus.Gauge
-	InkDepletionGaugeProm  prometheus.Gauge
-	GreaseBuildupGaugeOtel metric.Int64ObservableGauge
-	InkDepletionGaugeOtel  metric.Int64ObservableGauge
-)
+// ResourceGauge describes the gauge for a resource level
+type ResourceGauge struct {
+	Name        string
+	Description string
+	Level       func() int64
+}
 
-// InitGenteelGauges sets up metrics in both OTEL and Prometheus
-func InitGenteelGauges(appName string, commonAttribs []attribute.KeyValue, greaseGetter func() int64, inkGetter func() int64) error {
+// InitGenteelGauges sets up the resource gauges in both OTEL and Prometheus
+func InitGenteelGauges(appName string, commonAttribs []attribute.KeyValue, gauges []ResourceGauge) error {
 	meterProvider := otel.GetMeterProvider()
 	meter := meterProvider.Meter(appName)
 
-	// register the OTEL metrics
-	GreaseBuildupGaugeOtel, _ = meter.Int64ObservableGauge(
-		"genteelbeacon_greasebuildup",
-		metric.WithDescription("The Genteel Beacon's current grease buildup"),
-	)
-	InkDepletionGaugeOtel, _ = meter.Int64ObservableGauge(
-		"genteelbeacon_inkdepletion",
-		metric.WithDescription("The Genteel Beacon's current ink depletion"),
-	)
-
 	promLabels := make(prometheus.Labels)
 	for _, attr := range commonAttribs {
 		promLabels[string(attr.Key)] = attr.Value.AsString()
 	}
 
-	// register the Prometheus metrics
-	GreaseBuildupGaugeProm = promauto.NewGauge(prometheus.GaugeOpts{
-		Name:        "genteelbeacon_greasebuildup_p",
-		Help:        "The Genteel Beacon's current grease buildup",
-		ConstLabels: promLabels,
-	})
-	InkDepletionGaugeProm = promauto.NewGauge(prometheus.GaugeOpts{
-		Name:        "genteelbeacon_inkdepletion_p",
-		Help:        "The Genteel Beacon's current ink depletion",
-		ConstLabels: promLabels,
-	})
+	otelGauges := make([]metric.Int64ObservableGauge, len(gauges))
+	observables := make([]metric.Observable, len(gauges))
+	for i, gauge := range gauges {
+		// register the OTEL metric
+		otelGauges[i], _ = meter.Int64ObservableGauge(
+			gauge.Name,
+			metric.WithDescription(gauge.Description),
+		)
+		observables[i] = otelGauges[i]
 
-	// OTEL sending as callback on meter activity (from the channel handlers)
-	var err error = nil
-	_, err = meter.RegisterCallback(
-		func(ctx context.Context, observer metric.Observer) error {
-			// return the current value using the getter function
-			observer.ObserveInt64(GreaseBuildupGaugeOtel, greaseGetter(), metric.WithAttributes(commonAttribs...))
-			return nil
-		}, GreaseBuildupGaugeOtel)
-
-	if err != nil {
-		log.Fatalf("Failed to register callback: %v", err)
+		// register the Prometheus metric, read when scraped
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        gauge.Name + "_p",
+			Help:        gauge.Description,
+			ConstLabels: promLabels,
+		}, func() float64 {
+			return float64(gauge.Level())
+		})
 	}
-	_, err = meter.RegisterCallback(
+
+	// OTEL sending as callback on meter activity
+	_, err := meter.RegisterCallback(
 		func(ctx context.Context, observer metric.Observer) error {
-			// return the current value using the getter function
-			observer.ObserveInt64(InkDepletionGaugeOtel, inkGetter(), metric.WithAttributes(commonAttribs...))
+			// return the current values using the getter functions
+			for i, gauge := range gauges {
+				observer.ObserveInt64(otelGauges[i], gauge.Level(), metric.WithAttributes(commonAttribs...))
+			}
 			return nil
-		}, InkDepletionGaugeOtel)
+		}, observables...)
 
 	if err != nil {
 		log.Fatalf("Failed to register callback: %v", err)
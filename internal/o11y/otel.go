@@ -10,8 +10,11 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
@@ -22,17 +25,66 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
-// InitTracer initializes an OpenTelemetry tracer provider that exports traces to the specified OTLP HTTP endpoint.
-func InitTracer(otlphttpEndpoint string, commonAttribs []attribute.KeyValue) (*sdktrace.TracerProvider, error) {
+// newTraceExporter creates the trace exporter for the protocol
+func newTraceExporter(ctx context.Context, otlpExporter OtlpExporter) (sdktrace.SpanExporter, error) {
+	if otlpExporter.Protocol == ProtocolGRPC {
+		options := []otlptracegrpc.Option{otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(otlpExporter.Retry))}
+		if otlpExporter.Endpoint != "" {
+			options = append(options, otlptracegrpc.WithEndpoint(otlpExporter.Endpoint), otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, options...)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithRetry(otlptracehttp.RetryConfig(otlpExporter.Retry))}
+	if otlpExporter.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpoint(otlpExporter.Endpoint), otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(ctx, options...)
+}
+
+// newMetricExporter creates the metric exporter for the protocol
+func newMetricExporter(ctx context.Context, otlpExporter OtlpExporter) (sdkmetric.Exporter, error) {
+	if otlpExporter.Protocol == ProtocolGRPC {
+		options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(otlpExporter.Retry))}
+		if otlpExporter.Endpoint != "" {
+			options = append(options, otlpmetricgrpc.WithEndpoint(otlpExporter.Endpoint), otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(ctx, options...)
+	}
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(otlpExporter.Retry))}
+	if otlpExporter.Endpoint != "" {
+		options = append(options, otlpmetrichttp.WithEndpoint(otlpExporter.Endpoint), otlpmetrichttp.WithInsecure())
+	}
+	return otlpmetrichttp.New(ctx, options...)
+}
+
+// newLogExporter creates the log exporter for the protocol
+func newLogExporter(ctx context.Context, otlpExporter OtlpExporter) (sdklog.Exporter, error) {
+	if otlpExporter.Protocol == ProtocolGRPC {
+		options := []otlploggrpc.Option{otlploggrpc.WithRetry(otlploggrpc.RetryConfig(otlpExporter.Retry))}
+		if otlpExporter.Endpoint != "" {
+			options = append(options, otlploggrpc.WithEndpoint(otlpExporter.Endpoint), otlploggrpc.WithInsecure())
+		}
+		return otlploggrpc.New(ctx, options...)
+	}
+	options := []otlploghttp.Option{otlploghttp.WithRetry(otlploghttp.RetryConfig(otlpExporter.Retry))}
+	if otlpExporter.Endpoint != "" {
+		options = append(options, otlploghttp.WithEndpoint(otlpExporter.Endpoint), otlploghttp.WithInsecure())
+	}
+	return otlploghttp.New(ctx, options...)
+}
+
+// InitTracer initializes an OpenTelemetry tracer provider that exports traces via OTLP.
+func InitTracer(otlpExporter OtlpExporter, commonAttribs []attribute.KeyValue) (*sdktrace.TracerProvider, error) {
 	ctx := context.Background()
-	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(otlphttpEndpoint), otlptracehttp.WithInsecure())
+	exporter, err := newTraceExporter(ctx, otlpExporter)
 	if err != nil {
 		return nil, err
 	}
 
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithBatcher(exporter),
+		// the sampling policy may change at runtime
+		sdktrace.WithSampler(dynamicSampler{}),
+		sdktrace.WithSpanProcessor(errorPromoter{sdktrace.NewBatchSpanProcessor(exporter)}),
 		sdktrace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
 			commonAttribs...,
@@ -43,10 +95,10 @@ func InitTracer(otlphttpEndpoint string, commonAttribs []attribute.KeyValue) (*s
 	return tracerProvider, nil
 }
 
-// InitMeter initializes an OpenTelemetry meter provider that exports metrics to the specified OTLP HTTP endpoint.
-func InitMeter(otlphttpEndpoint string, commonAttribs []attribute.KeyValue) (*sdkmetric.MeterProvider, error) {
+// InitMeter initializes an OpenTelemetry meter provider that exports metrics via OTLP.
+func InitMeter(otlpExporter OtlpExporter, commonAttribs []attribute.KeyValue) (*sdkmetric.MeterProvider, error) {
 	ctx := context.Background()
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint(otlphttpEndpoint), otlpmetrichttp.WithInsecure())
+	metricExporter, err := newMetricExporter(ctx, otlpExporter)
 	if err != nil {
 		return nil, err
 	}
@@ -63,10 +115,10 @@ func InitMeter(otlphttpEndpoint string, commonAttribs []attribute.KeyValue) (*sd
 	return meterProvider, nil
 }
 
-// InitOtelLogger initializes an OpenTelemetry logger provider that exports logs to the specified OTLP HTTP endpoint.
-func InitOtelLogger(otlphttpEndpoint string, commonAttribs []attribute.KeyValue) (*sdklog.LoggerProvider, error) {
+// InitOtelLogger initializes an OpenTelemetry logger provider that exports logs via OTLP.
+func InitOtelLogger(otlpExporter OtlpExporter, commonAttribs []attribute.KeyValue) (*sdklog.LoggerProvider, error) {
 	ctx := context.Background()
-	logExporter, err := otlploghttp.New(ctx, otlploghttp.WithEndpoint(otlphttpEndpoint), otlploghttp.WithInsecure())
+	logExporter, err := newLogExporter(ctx, otlpExporter)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,121 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"context"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// the outcomes of chaos and resources we count
+const (
+	OutcomeBreak      = "break"
+	OutcomeIndisposed = "indisposed"
+	OutcomePenDrop    = "pendrop"
+	OutcomeTrip       = "trip"
+	OutcomeLatency    = "latency"
+	OutcomeLineDown   = "linedown"
+	OutcomeFault      = "fault"
+	OutcomeStall      = "stall"
+)
+
+// the decisions of the courier we count, about retries, breakers, fallbacks and the clocks
+const (
+	DecisionTimeout         = "timeout"
+	DecisionRetry           = "retry"
+	DecisionBreakerOpened   = "breaker_opened"
+	DecisionBreakerRejected = "breaker_rejected"
+	DecisionBreakerProbe    = "breaker_probe"
+	DecisionBreakerClosed   = "breaker_closed"
+	DecisionFallback        = "fallback"
+	DecisionHedge           = "hedge"
+	DecisionSkew            = "skew"
+)
+
+var (
+	outcomesOtel   metric.Int64Counter
+	deliveriesOtel metric.Int64Counter
+	decisionsOtel  metric.Int64Counter
+	outcomesProm   *prometheus.CounterVec
+	deliveriesProm *prometheus.CounterVec
+	decisionsProm  *prometheus.CounterVec
+	outcomeAttribs []attribute.KeyValue
+)
+
+// InitOutcomeMetrics sets up the counters of chaos outcomes, deliveries and courier decisions in both OTEL and Prometheus,
+// the role is part of the common attributes
+func InitOutcomeMetrics(appName string, commonAttribs []attribute.KeyValue) {
+	meter := otel.GetMeterProvider().Meter(appName)
+	outcomeAttribs = slices.Clip(commonAttribs)
+
+	outcomesOtel, _ = meter.Int64Counter(
+		"genteelbeacon_chaos_outcomes",
+		metric.WithDescription("The chaos and resource trip outcomes, e.g. a clerk on a break or a tripped ink well"),
+	)
+	deliveriesOtel, _ = meter.Int64Counter(
+		"genteelbeacon_deliveries",
+		metric.WithDescription("The telegrams and calling cards successfully delivered"),
+	)
+	decisionsOtel, _ = meter.Int64Counter(
+		"genteelbeacon_courier_decisions",
+		metric.WithDescription("The decisions of the courier's resilience policy, e.g. a retry or falling back to the local date"),
+	)
+
+	promLabels := make(prometheus.Labels)
+	for _, attr := range commonAttribs {
+		promLabels[string(attr.Key)] = attr.Value.AsString()
+	}
+	outcomesProm = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:        "genteelbeacon_chaos_outcomes_total",
+		Help:        "The chaos and resource trip outcomes, e.g. a clerk on a break or a tripped ink well",
+		ConstLabels: promLabels,
+	}, []string{"outcome", "service", "gate"})
+	deliveriesProm = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:        "genteelbeacon_deliveries_total",
+		Help:        "The telegrams and calling cards successfully delivered",
+		ConstLabels: promLabels,
+	}, []string{"kind"})
+	decisionsProm = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:        "genteelbeacon_courier_decisions_total",
+		Help:        "The decisions of the courier's resilience policy, e.g. a retry or falling back to the local date",
+		ConstLabels: promLabels,
+	}, []string{"decision", "clock"})
+}
+
+// RecordOutcome counts a chaos outcome of the service at the gate, e.g. OutcomeBreak of DiligentClerk at breakChance
+func RecordOutcome(ctx context.Context, outcome string, service string, gate string) {
+	if outcomesProm == nil {
+		return
+	}
+	outcomesOtel.Add(ctx, 1, metric.WithAttributes(append(outcomeAttribs,
+		attribute.String("outcome", outcome), attribute.String("service", service), attribute.String("gate", gate))...))
+	outcomesProm.WithLabelValues(outcome, service, gate).Inc()
+}
+
+// RecordDelivery counts a delivery of the kind, "telegram" or "callingcard"
+func RecordDelivery(ctx context.Context, kind string) {
+	if deliveriesProm == nil {
+		return
+	}
+	deliveriesOtel.Add(ctx, 1, metric.WithAttributes(append(outcomeAttribs, attribute.String("kind", kind))...))
+	deliveriesProm.WithLabelValues(kind).Inc()
+}
+
+// RecordCourierDecision counts a decision of the courier's resilience policy about the clock, e.g. DecisionRetry
+func RecordCourierDecision(ctx context.Context, decision string, clock string) {
+	if decisionsProm == nil {
+		return
+	}
+	decisionsOtel.Add(ctx, 1, metric.WithAttributes(append(outcomeAttribs,
+		attribute.String("decision", decision), attribute.String("clock", clock))...))
+	decisionsProm.WithLabelValues(decision, clock).Inc()
+}
@@ -0,0 +1,291 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingPolicy decides which traces are kept.
+// The sampler is one of the standard OTEL_TRACES_SAMPLER values or "rules".
+type SamplingPolicy struct {
+	Sampler string  `json:"sampler"`
+	Ratio   float64 `json:"ratio"`
+	// rules match the name of root spans, i.e. the path, a trailing * matches a prefix
+	Rules []SamplingRule `json:"rules"`
+	// spans with these names that end in an error are kept, even if their trace was not sampled
+	KeepErrors []string `json:"keepErrors"`
+	// whether their ancestors are kept too, which has every span recorded, sampled or not
+	KeepAncestors bool `json:"keepAncestors"`
+}
+
+// SamplingRule is the ratio to sample matching traces with
+type SamplingRule struct {
+	Span  string  `json:"span"`
+	Ratio float64 `json:"ratio"`
+}
+
+var (
+	// the policy from the environment, flags may replace it at runtime
+	envSamplingPolicy SamplingPolicy
+	samplingPolicy    atomic.Pointer[SamplingPolicy]
+	currentSampler    atomic.Pointer[sdktrace.Sampler]
+	// traces with a kept error and when it was kept, so their ancestors are kept as well
+	promotedTraces sync.Map
+)
+
+// how long the ancestors of a kept error may take to end, the trace is forgotten after
+const promotionTTL = time.Minute
+
+func init() {
+	envSamplingPolicy = SamplingPolicy{Sampler: "always_on", Ratio: 1}
+	_ = SetSamplingPolicy(envSamplingPolicy)
+}
+
+// InitSampling reads the sampling policy from OTEL_TRACES_SAMPLER and our own variables
+func InitSampling() error {
+	policy := SamplingPolicy{
+		Sampler: os.Getenv("OTEL_TRACES_SAMPLER"),
+		Ratio:   1,
+	}
+	if policy.Sampler == "" {
+		policy.Sampler = "always_on"
+	}
+	if samplerArg, ok := os.LookupEnv("OTEL_TRACES_SAMPLER_ARG"); ok {
+		ratio, err := strconv.ParseFloat(samplerArg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG: %w", err)
+		}
+		policy.Ratio = ratio
+	}
+	for rule := range strings.SplitSeq(os.Getenv("GENTEEL_SAMPLING_RULES"), ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		span, ratio, found := strings.Cut(rule, "=")
+		if !found {
+			return fmt.Errorf("invalid sampling rule %q", rule)
+		}
+		ratioVal, err := strconv.ParseFloat(ratio, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ratio in sampling rule %q", rule)
+		}
+		policy.Rules = append(policy.Rules, SamplingRule{Span: strings.TrimSpace(span), Ratio: ratioVal})
+	}
+	keepErrors, ok := os.LookupEnv("GENTEEL_SAMPLING_KEEP_ERRORS")
+	if !ok {
+		keepErrors = "GreaseGrate,InkWell"
+	}
+	for span := range strings.SplitSeq(keepErrors, ",") {
+		if span = strings.TrimSpace(span); span != "" {
+			policy.KeepErrors = append(policy.KeepErrors, span)
+		}
+	}
+	if keepAncestors, ok := os.LookupEnv("GENTEEL_SAMPLING_KEEP_ANCESTORS"); ok {
+		var err error
+		if policy.KeepAncestors, err = strconv.ParseBool(keepAncestors); err != nil {
+			return fmt.Errorf("invalid GENTEEL_SAMPLING_KEEP_ANCESTORS: %w", err)
+		}
+	}
+	if err := SetSamplingPolicy(policy); err != nil {
+		return err
+	}
+	envSamplingPolicy = policy
+	return nil
+}
+
+// SamplingPolicyFromMap reads a policy from a flag value
+func SamplingPolicyFromMap(settings map[string]any) (SamplingPolicy, error) {
+	policy := SamplingPolicy{Ratio: 1}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(settingsJSON, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// SetSamplingPolicy switches to the policy, for all spans started from now on
+func SetSamplingPolicy(policy SamplingPolicy) error {
+	sampler, err := policy.sampler()
+	if err != nil {
+		return err
+	}
+	samplingPolicy.Store(&policy)
+	currentSampler.Store(&sampler)
+	return nil
+}
+
+// CurrentSamplingPolicy tells the policy in effect
+func CurrentSamplingPolicy() SamplingPolicy {
+	return *samplingPolicy.Load()
+}
+
+// ResetSamplingPolicy goes back to the policy from the environment
+func ResetSamplingPolicy() {
+	_ = SetSamplingPolicy(envSamplingPolicy)
+}
+
+// sampler creates the sampler for the policy
+func (p SamplingPolicy) sampler() (sdktrace.Sampler, error) {
+	if p.Ratio < 0 || p.Ratio > 1 {
+		return nil, fmt.Errorf("sampling ratio %f must be between 0 and 1", p.Ratio)
+	}
+	for _, rule := range p.Rules {
+		if rule.Ratio < 0 || rule.Ratio > 1 {
+			return nil, fmt.Errorf("sampling ratio %f for %s must be between 0 and 1", rule.Ratio, rule.Span)
+		}
+	}
+	var sampler sdktrace.Sampler
+	switch p.Sampler {
+	case "", "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "traceidratio":
+		sampler = sdktrace.TraceIDRatioBased(p.Ratio)
+	case "parentbased_always_on":
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		sampler = sdktrace.ParentBased(sdktrace.NeverSample())
+	case "parentbased_traceidratio":
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(p.Ratio))
+	case "rules":
+		sampler = ruleSampler{policy: p}
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", p.Sampler)
+	}
+	if len(p.KeepErrors) > 0 {
+		sampler = keepErrorsSampler{Sampler: sampler, keep: p.KeepErrors, ancestors: p.KeepAncestors}
+	}
+	return sampler, nil
+}
+
+// keepErrorsSampler still records the spans the sampler drops that may be promoted,
+// those with kept errors and, if their ancestors are kept too, every span
+type keepErrorsSampler struct {
+	sdktrace.Sampler
+	keep      []string
+	ancestors bool
+}
+
+func (s keepErrorsSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.Sampler.ShouldSample(parameters)
+	if result.Decision == sdktrace.Drop && (s.ancestors || slices.Contains(s.keep, parameters.Name)) {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (s keepErrorsSampler) Description() string {
+	return "KeepErrors{" + s.Sampler.Description() + "}"
+}
+
+// ruleSampler samples root spans by the first matching rule and follows the parent otherwise
+type ruleSampler struct {
+	policy SamplingPolicy
+}
+
+func (s ruleSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(parameters.ParentContext)
+	if parent.IsValid() {
+		decision := sdktrace.Drop
+		if parent.IsSampled() {
+			decision = sdktrace.RecordAndSample
+		}
+		return sdktrace.SamplingResult{Decision: decision, Tracestate: parent.TraceState()}
+	}
+
+	ratio := s.policy.Ratio
+	for _, rule := range s.policy.Rules {
+		if prefix, ok := strings.CutSuffix(rule.Span, "*"); (ok && strings.HasPrefix(parameters.Name, prefix)) || rule.Span == parameters.Name {
+			ratio = rule.Ratio
+			break
+		}
+	}
+	return sdktrace.TraceIDRatioBased(ratio).ShouldSample(parameters)
+}
+
+func (s ruleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{ratio:%g,rules:%d}", s.policy.Ratio, len(s.policy.Rules))
+}
+
+// dynamicSampler delegates to the sampler of the current policy
+type dynamicSampler struct{}
+
+func (dynamicSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*currentSampler.Load()).ShouldSample(parameters)
+}
+
+func (dynamicSampler) Description() string {
+	return "Dynamic{" + (*currentSampler.Load()).Description() + "}"
+}
+
+// errorPromoter passes on sampled spans as well as recorded spans that are kept for their errors,
+// together with their ancestors, which end after them
+type errorPromoter struct {
+	sdktrace.SpanProcessor
+}
+
+// promotedSpan is a recorded span that is exported as if it was sampled
+type promotedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s promotedSpan) SpanContext() trace.SpanContext {
+	return s.ReadOnlySpan.SpanContext().WithTraceFlags(trace.FlagsSampled)
+}
+
+func (p errorPromoter) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	traceID := s.SpanContext().TraceID()
+	// the local root ends last, nothing of the trace will follow
+	isRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+	_, promoted := promotedTraces.Load(traceID)
+	if isRoot {
+		promotedTraces.Delete(traceID)
+	}
+	policy := samplingPolicy.Load()
+	if !promoted && s.Status().Code == codes.Error && slices.Contains(policy.KeepErrors, s.Name()) {
+		promoted = true
+		if !isRoot && policy.KeepAncestors {
+			promoteTrace(traceID)
+		}
+	}
+	if promoted {
+		p.SpanProcessor.OnEnd(promotedSpan{s})
+	}
+}
+
+// promoteTrace keeps the ancestors of the trace's kept error, forgetting the traces whose root didn't end in time,
+// e.g. as it ended before the error or was never recorded here
+func promoteTrace(traceID trace.TraceID) {
+	now := time.Now()
+	promotedTraces.Range(func(key, promotedAt any) bool {
+		if now.Sub(promotedAt.(time.Time)) > promotionTTL {
+			promotedTraces.Delete(key)
+		}
+		return true
+	})
+	promotedTraces.Store(traceID, now)
+}
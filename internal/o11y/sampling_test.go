@@ -0,0 +1,89 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKeepErrorsSampler(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SamplingPolicy
+		span   string
+		want   sdktrace.SamplingDecision
+	}{
+		{
+			name:   "sampled",
+			policy: SamplingPolicy{Sampler: "always_on", KeepErrors: []string{"InkWell"}},
+			span:   "/telegram",
+			want:   sdktrace.RecordAndSample,
+		},
+		{
+			name:   "kept span recorded",
+			policy: SamplingPolicy{Sampler: "always_off", KeepErrors: []string{"InkWell"}},
+			span:   "InkWell",
+			want:   sdktrace.RecordOnly,
+		},
+		{
+			name:   "other span dropped",
+			policy: SamplingPolicy{Sampler: "always_off", KeepErrors: []string{"InkWell"}},
+			span:   "/telegram",
+			want:   sdktrace.Drop,
+		},
+		{
+			name:   "ancestor recorded",
+			policy: SamplingPolicy{Sampler: "always_off", KeepErrors: []string{"InkWell"}, KeepAncestors: true},
+			span:   "/telegram",
+			want:   sdktrace.RecordOnly,
+		},
+		{
+			name:   "nothing kept",
+			policy: SamplingPolicy{Sampler: "always_off", KeepAncestors: true},
+			span:   "InkWell",
+			want:   sdktrace.Drop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := tt.policy.sampler()
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := sampler.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: context.Background(),
+				TraceID:       trace.TraceID{1},
+				Name:          tt.span,
+			})
+			if result.Decision != tt.want {
+				t.Errorf("decision %v, want %v", result.Decision, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromotedTracesForgotten(t *testing.T) {
+	stale, fresh := trace.TraceID{1}, trace.TraceID{2}
+	t.Cleanup(func() {
+		promotedTraces.Delete(stale)
+		promotedTraces.Delete(fresh)
+	})
+	// its root ended before the error
+	promotedTraces.Store(stale, time.Now().Add(-2*promotionTTL))
+
+	promoteTrace(fresh)
+	if _, ok := promotedTraces.Load(stale); ok {
+		t.Error("stale trace still promoted")
+	}
+	if _, ok := promotedTraces.Load(fresh); !ok {
+		t.Error("fresh trace not promoted")
+	}
+}
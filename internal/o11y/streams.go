@@ -0,0 +1,82 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package o11y
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// streams last from seconds to hours
+var streamBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+var (
+	openStreamsOtel    metric.Int64UpDownCounter
+	streamDurationOtel metric.Float64Histogram
+	openStreamsProm    *prometheus.GaugeVec
+	streamDurationProm *prometheus.HistogramVec
+	streamAttribs      []attribute.KeyValue
+)
+
+// InitStreamMetrics sets up the metrics of long-lived connections in both OTEL and Prometheus
+func InitStreamMetrics(appName string, commonAttribs []attribute.KeyValue) {
+	meter := otel.GetMeterProvider().Meter(appName)
+	streamAttribs = slices.Clip(commonAttribs)
+
+	openStreamsOtel, _ = meter.Int64UpDownCounter(
+		"genteelbeacon_open_streams",
+		metric.WithDescription("The streams currently open, e.g. telegram streams via SSE"),
+	)
+	streamDurationOtel, _ = meter.Float64Histogram(
+		"genteelbeacon_stream_duration",
+		metric.WithDescription("How long streams stay open"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(streamBuckets...),
+	)
+
+	promLabels := make(prometheus.Labels)
+	for _, attr := range commonAttribs {
+		promLabels[string(attr.Key)] = attr.Value.AsString()
+	}
+	openStreamsProm = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "genteelbeacon_open_streams",
+		Help:        "The streams currently open, e.g. telegram streams via SSE",
+		ConstLabels: promLabels,
+	}, []string{"kind"})
+	streamDurationProm = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "genteelbeacon_stream_duration_seconds",
+		Help:        "How long streams stay open",
+		ConstLabels: promLabels,
+		Buckets:     streamBuckets,
+	}, []string{"kind"})
+}
+
+// StreamOpened counts a stream of the kind, e.g. "sse", as open
+func StreamOpened(ctx context.Context, kind string) {
+	if openStreamsProm == nil {
+		return
+	}
+	openStreamsOtel.Add(ctx, 1, metric.WithAttributes(append(streamAttribs, attribute.String("kind", kind))...))
+	openStreamsProm.WithLabelValues(kind).Inc()
+}
+
+// StreamClosed counts the stream as closed and records how long it was open
+func StreamClosed(ctx context.Context, kind string, duration time.Duration) {
+	if openStreamsProm == nil {
+		return
+	}
+	openStreamsOtel.Add(ctx, -1, metric.WithAttributes(append(streamAttribs, attribute.String("kind", kind))...))
+	openStreamsProm.WithLabelValues(kind).Dec()
+	streamDurationOtel.Record(ctx, duration.Seconds(), metric.WithAttributes(append(streamAttribs, attribute.String("kind", kind))...))
+	observeWithTrace(ctx, streamDurationProm.WithLabelValues(kind), duration.Seconds())
+}
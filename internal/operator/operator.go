@@ -0,0 +1,187 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"github.com/fasthttp/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type operatorSettings struct {
+	line     string
+	interval time.Duration
+	timeout  time.Duration
+	messages []string
+}
+
+// telegraphReply is either a telegram or, with a status, why there is none
+type telegraphReply struct {
+	types.Telegram
+	Status int `json:"status"`
+}
+
+// readSettings gets the operator's work from GENTEEL_OPERATOR_* environment variables
+func readSettings() (operatorSettings, error) {
+	var settings operatorSettings
+	var err error
+
+	settings.line = config.GetEnv("GENTEEL_OPERATOR_LINE", "ws://localhost:1333/telegraph")
+	if !strings.HasPrefix(settings.line, "ws://") && !strings.HasPrefix(settings.line, "wss://") {
+		return settings, errors.New("GENTEEL_OPERATOR_LINE must be a ws:// or wss:// address")
+	}
+	if settings.interval, err = time.ParseDuration(config.GetEnv("GENTEEL_OPERATOR_INTERVAL", "1s")); err != nil || settings.interval <= 0 {
+		return settings, errors.New("GENTEEL_OPERATOR_INTERVAL must be a positive duration")
+	}
+	if settings.timeout, err = time.ParseDuration(config.GetEnv("GENTEEL_OPERATOR_TIMEOUT", "30s")); err != nil || settings.timeout <= 0 {
+		return settings, errors.New("GENTEEL_OPERATOR_TIMEOUT must be a positive duration")
+	}
+	for message := range strings.SplitSeq(config.GetEnv("GENTEEL_OPERATOR_MESSAGES", "Arrived safely,Weather fine,Send more ink,Regards to the Schildwächter"), ",") {
+		if message = strings.TrimSpace(message); message != "" {
+			settings.messages = append(settings.messages, message)
+		}
+	}
+	if len(settings.messages) == 0 {
+		return settings, errors.New("GENTEEL_OPERATOR_MESSAGES needs at least one message")
+	}
+	return settings, nil
+}
+
+// RunOperator is what we run in operator mode, sending messages down the telegraph line until the context is done
+func RunOperator(ctx context.Context) error {
+	settings, err := readSettings()
+	if err != nil {
+		o11y.Logger.Error("Invalid operator settings: " + err.Error())
+		return err
+	}
+	o11y.Logger.Info(fmt.Sprintf("Operating the telegraph line to %s every %s", settings.line, settings.interval))
+
+	for ctx.Err() == nil {
+		if err := workLine(ctx, settings); err != nil {
+			o11y.Logger.Warn("Telegraph line down: " + err.Error())
+		}
+		// call again after a moment
+		sleep(ctx, settings.interval)
+	}
+	o11y.Logger.Info("Stopping the telegraph operator")
+	return nil
+}
+
+// workLine calls the telegraphist and sends messages until the line drops or the context is done
+func workLine(ctx context.Context, settings operatorSettings) error {
+	lineCtx, span := otel.Tracer(config.AppName).Start(ctx, "OperatorLine", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(attribute.String("genteel.operator.line", settings.line))
+
+	// the handshake carries the line's trace context
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(lineCtx, propagation.HeaderCarrier(header))
+	dialCtx, cancel := context.WithTimeout(lineCtx, settings.timeout)
+	conn, _, err := websocket.DefaultDialer.DialContext(dialCtx, settings.line, header)
+	cancel()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer conn.Close()
+	o11y.Logger.InfoContext(lineCtx, "Telegraph line open", o11y.LoggerTraceAttr(lineCtx, span), o11y.LoggerSpanAttr(lineCtx, span))
+
+	// hang up properly when we are done
+	stopHangUp := context.AfterFunc(ctx, func() {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "off duty"), time.Now().Add(time.Second))
+		_ = conn.Close()
+	})
+	defer stopHangUp()
+
+	for ctx.Err() == nil {
+		if err := sendMessage(lineCtx, conn, settings); err != nil {
+			if ctx.Err() != nil {
+				// we hung up ourselves
+				return nil
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		sleep(ctx, settings.interval)
+	}
+	return nil
+}
+
+// sendMessage sends a message with its trace context and waits for the telegram
+func sendMessage(lineCtx context.Context, conn *websocket.Conn, settings operatorSettings) error {
+	// every message is a trace of its own, the line may go on for hours
+	ctx, span := otel.Tracer(config.AppName).Start(lineCtx, "OperatorMessage",
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(lineCtx)))
+	defer span.End()
+
+	message := types.TelegraphMessage{
+		Message: settings.messages[rand.IntN(len(settings.messages))],
+		Carrier: make(map[string]string),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(message.Carrier))
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(settings.timeout))
+	if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// the telegraphist may keep us waiting while out of ink
+	_ = conn.SetReadDeadline(time.Now().Add(settings.timeout))
+	_, answer, err := conn.ReadMessage()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	var reply telegraphReply
+	if err := json.Unmarshal(answer, &reply); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if reply.Status != 0 {
+		// the line is fine, the telegram just didn't make it
+		err := fmt.Errorf("%d %s", reply.Status, reply.Message)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Logger.WarnContext(ctx, "No telegram: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		return nil
+	}
+	span.SetAttributes(attribute.String("genteel.telegram.identifier", reply.Identifier))
+	o11y.Logger.DebugContext(ctx, "Telegram received: "+reply.Message, o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	return nil
+}
+
+// sleep waits for the duration, unless the context is done first
+func sleep(ctx context.Context, duration time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+}
@@ -0,0 +1,49 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+)
+
+// memoryQueue keeps the messages in a channel, they don't leave the process
+type memoryQueue struct {
+	messages chan Message
+}
+
+func newMemoryQueue(capacity int) *memoryQueue {
+	return &memoryQueue{messages: make(chan Message, capacity)}
+}
+
+func (q *memoryQueue) Publish(ctx context.Context, message Message) error {
+	select {
+	case q.messages <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *memoryQueue) Messages() <-chan Message {
+	return q.messages
+}
+
+func (q *memoryQueue) Depth() int {
+	return len(q.messages)
+}
+
+func (q *memoryQueue) System() string {
+	return config.QueueMemory
+}
+
+func (q *memoryQueue) Destination() string {
+	return "telegrams"
+}
+
+// Close does nothing, producers may still be around and the channel goes with the queue
+func (q *memoryQueue) Close() {}
@@ -0,0 +1,142 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// beacons sharing a NATS server share the work
+const natsQueueGroup = "telegraphists"
+
+// natsQueue sends the messages via NATS, through an embedded server unless told to use another one
+type natsQueue struct {
+	server   *server.Server
+	conn     *nats.Conn
+	sub      *nats.Subscription
+	subject  string
+	capacity int
+	messages chan Message
+	closed   chan struct{}
+}
+
+func newNatsQueue(policy config.QueuePolicy) (*natsQueue, error) {
+	q := &natsQueue{
+		subject:  policy.Subject,
+		capacity: policy.Capacity,
+		messages: make(chan Message, policy.Capacity),
+		closed:   make(chan struct{}),
+	}
+	options := []nats.Option{
+		nats.Name(config.AppName),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			// e.g. the workers not keeping up
+			o11y.Logger.Warn("NATS trouble: " + err.Error())
+		}),
+	}
+	if policy.NatsURL == "" {
+		serverOptions := &server.Options{
+			ServerName: config.NodeName,
+			NoLog:      true,
+			NoSigs:     true,
+			DontListen: policy.NatsPort == 0,
+			Port:       policy.NatsPort,
+		}
+		var err error
+		if q.server, err = server.NewServer(serverOptions); err != nil {
+			return nil, err
+		}
+		go q.server.Start()
+		if !q.server.ReadyForConnections(5 * time.Second) {
+			q.server.Shutdown()
+			return nil, errors.New("embedded NATS server not ready")
+		}
+		options = append(options, nats.InProcessServer(q.server))
+		if policy.NatsPort != 0 {
+			o11y.Logger.Info("Embedded NATS server listening on " + q.server.ClientURL())
+		}
+	}
+
+	var err error
+	if q.conn, err = nats.Connect(policy.NatsURL, options...); err != nil {
+		q.Close()
+		return nil, err
+	}
+	// waiting here holds up NATS, which drops messages beyond the capacity
+	q.sub, err = q.conn.QueueSubscribe(q.subject, natsQueueGroup, func(msg *nats.Msg) {
+		message := Message{Headers: make(map[string]string), Body: msg.Data}
+		for key, values := range msg.Header {
+			// the propagators expect lowercase keys
+			if len(values) > 0 {
+				message.Headers[strings.ToLower(key)] = values[0]
+			}
+		}
+		select {
+		case q.messages <- message:
+		case <-q.closed:
+		}
+	})
+	if err == nil {
+		err = q.sub.SetPendingLimits(policy.Capacity, -1)
+	}
+	if err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *natsQueue) Publish(ctx context.Context, message Message) error {
+	// as far as we can tell, others may be waiting elsewhere
+	if q.Depth() >= q.capacity {
+		return ErrQueueFull
+	}
+	msg := nats.NewMsg(q.subject)
+	for key, value := range message.Headers {
+		msg.Header.Set(key, value)
+	}
+	msg.Data = message.Body
+	return q.conn.PublishMsg(msg)
+}
+
+func (q *natsQueue) Messages() <-chan Message {
+	return q.messages
+}
+
+func (q *natsQueue) Depth() int {
+	pending := 0
+	if q.sub != nil {
+		pending, _, _ = q.sub.Pending()
+	}
+	return len(q.messages) + pending
+}
+
+func (q *natsQueue) System() string {
+	return config.QueueNats
+}
+
+func (q *natsQueue) Destination() string {
+	return q.subject
+}
+
+func (q *natsQueue) Close() {
+	close(q.closed)
+	if q.conn != nil {
+		q.conn.Close()
+	}
+	if q.server != nil {
+		q.server.Shutdown()
+	}
+}
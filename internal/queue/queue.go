@@ -0,0 +1,50 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package queue carries messages from producers to workers, in-process or via NATS.
+package queue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+)
+
+// ErrQueueFull is returned when no more messages may wait
+var ErrQueueFull = errors.New("queue is full")
+
+// Message is what goes through the queue, the headers carry the trace context
+type Message struct {
+	Headers map[string]string
+	Body    []byte
+}
+
+// Queue takes messages from producers and hands them to the workers
+type Queue interface {
+	// Publish puts the message on the queue, ErrQueueFull if it can't take more
+	Publish(ctx context.Context, message Message) error
+	// Messages delivers the messages to the workers
+	Messages() <-chan Message
+	// Depth tells how many messages are waiting
+	Depth() int
+	// System names the queue for the spans, e.g. memory or nats
+	System() string
+	// Destination names where the messages go, for the spans
+	Destination() string
+	// Close lets go of the queue, messages still waiting are lost
+	Close()
+}
+
+// New sets up the queue of the configured kind
+func New() (Queue, error) {
+	switch config.Queue.Kind {
+	case config.QueueMemory:
+		return newMemoryQueue(config.Queue.Capacity), nil
+	case config.QueueNats:
+		return newNatsQueue(config.Queue)
+	}
+	return nil, errors.New("no queue configured")
+}
@@ -0,0 +1,115 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+)
+
+// the states of a circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// circuitBreaker stops calls to a clock after too many failures in a row,
+// once cooled down a single probe decides whether to close it again
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+var (
+	breakers   = make(map[string]*circuitBreaker)
+	breakersMu sync.Mutex
+)
+
+// breakerFor returns the breaker of the clock, every clock has its own
+func breakerFor(clock string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breaker, ok := breakers[clock]
+	if !ok {
+		breaker = &circuitBreaker{state: BreakerClosed}
+		breakers[clock] = breaker
+	}
+	return breaker
+}
+
+// BreakerStates tells the state of the breaker of every clock asked so far
+func BreakerStates() map[string]string {
+	breakersMu.Lock()
+	clocks := maps.Clone(breakers)
+	breakersMu.Unlock()
+	states := make(map[string]string)
+	for clock, breaker := range clocks {
+		breaker.mu.Lock()
+		states[clock] = breaker.state
+		breaker.mu.Unlock()
+	}
+	return states
+}
+
+// allow tells whether a call may go ahead and whether it is the probe of a half-open breaker
+func (b *circuitBreaker) allow() (allowed bool, probe bool) {
+	if config.Courier.BreakerThreshold == 0 {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < config.Courier.BreakerCooldown {
+			return false, false
+		}
+		b.state = BreakerHalfOpen
+		return true, true
+	case BreakerHalfOpen:
+		// the probe is still out
+		return false, false
+	}
+	return true, false
+}
+
+// record takes note of the call's result and returns the state the breaker changed to, if any
+func (b *circuitBreaker) record(success bool) string {
+	if config.Courier.BreakerThreshold == 0 {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.state = BreakerClosed
+			return BreakerClosed
+		}
+		return ""
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= config.Courier.BreakerThreshold) {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		return BreakerOpen
+	}
+	return ""
+}
+
+// abandon forgets about a call given up by the caller, a probe may be sent again right away
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
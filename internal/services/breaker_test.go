@@ -0,0 +1,126 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+)
+
+// breakerStep does one thing to the breaker and tells what came of it
+func breakerStep(b *circuitBreaker, action string) string {
+	switch action {
+	case "ask":
+		allowed, probe := b.allow()
+		if probe {
+			return "probe"
+		}
+		if allowed {
+			return "allowed"
+		}
+		return "rejected"
+	case "fail":
+		return b.record(false)
+	case "succeed":
+		return b.record(true)
+	case "abandon":
+		b.abandon()
+	case "cool":
+		// as if the cooldown had passed
+		b.openedAt = b.openedAt.Add(-config.Courier.BreakerCooldown)
+	}
+	return ""
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	policy := config.Courier
+	t.Cleanup(func() { config.Courier = policy })
+	config.Courier.BreakerCooldown = time.Minute
+
+	tests := []struct {
+		name      string
+		threshold int
+		actions   []string
+		want      []string // what came of each action
+		wantState string
+	}{
+		{
+			name:      "closed below the threshold",
+			threshold: 2,
+			actions:   []string{"fail", "ask"},
+			want:      []string{"", "allowed"},
+			wantState: BreakerClosed,
+		},
+		{
+			name:      "success resets the failures",
+			threshold: 2,
+			actions:   []string{"fail", "succeed", "fail", "ask"},
+			want:      []string{"", "", "", "allowed"},
+			wantState: BreakerClosed,
+		},
+		{
+			name:      "opens at the threshold",
+			threshold: 2,
+			actions:   []string{"fail", "fail", "ask"},
+			want:      []string{"", BreakerOpen, "rejected"},
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "half-open after the cooldown",
+			threshold: 2,
+			actions:   []string{"fail", "fail", "cool", "ask", "ask"},
+			want:      []string{"", BreakerOpen, "", "probe", "rejected"},
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name:      "closes when the probe succeeds",
+			threshold: 2,
+			actions:   []string{"fail", "fail", "cool", "ask", "succeed", "ask"},
+			want:      []string{"", BreakerOpen, "", "probe", BreakerClosed, "allowed"},
+			wantState: BreakerClosed,
+		},
+		{
+			name:      "opens again when the probe fails",
+			threshold: 2,
+			actions:   []string{"fail", "fail", "cool", "ask", "fail", "ask"},
+			want:      []string{"", BreakerOpen, "", "probe", BreakerOpen, "rejected"},
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "probes again after an abandoned probe",
+			threshold: 2,
+			actions:   []string{"fail", "fail", "cool", "ask", "abandon", "ask"},
+			want:      []string{"", BreakerOpen, "", "probe", "", "probe"},
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name:      "never opens without a threshold",
+			threshold: 0,
+			actions:   []string{"fail", "fail", "fail", "ask"},
+			want:      []string{"", "", "", "allowed"},
+			wantState: BreakerClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Courier.BreakerThreshold = tt.threshold
+			breaker := &circuitBreaker{state: BreakerClosed}
+			var got []string
+			for _, action := range tt.actions {
+				got = append(got, breakerStep(breaker, action))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if breaker.state != tt.wantState {
+				t.Errorf("state %q, want %q", breaker.state, tt.wantState)
+			}
+		})
+	}
+}
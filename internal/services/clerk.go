@@ -18,15 +18,20 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 )
 
-// DiligentClerk creates the telegram to be sent
-func DiligentClerk(ctx context.Context, clockResponseData types.ClockReading, useClock bool, requestID string) (types.Telegram, error) {
+// DiligentClerk creates the telegram to be sent, telling the time or transcribing the sender's words if there are any
+func DiligentClerk(ctx context.Context, clockResponseData types.ClockReading, useClock bool, requestID string, transcript string) (_ types.Telegram, err error) {
 	ctx, span := otel.Tracer(config.AppName).Start(ctx, "DiligentClerk")
 	defer span.End()
+	defer func(start time.Time) {
+		o11y.RecordServiceDuration(ctx, "DiligentClerk", time.Since(start), err)
+	}(time.Now())
 
-	o11y.Logger.DebugContext(ctx, "Clerk at work 🖊️")
+	o11y.Component(o11y.ComponentClerk).DebugContext(ctx, "Clerk at work 🖊️")
+	LatencyGate(ctx, "DiligentClerk", "clerkLatency")
 
 	nodeName, err := os.Hostname()
 	if err != nil {
@@ -41,7 +46,7 @@ func DiligentClerk(ctx context.Context, clockResponseData types.ClockReading, us
 	responseTelegram.Service = config.AppName
 	responseTelegram.Telegraphist = nodeName
 	responseTelegram.FormVersion = config.BuildVersion
-	parsedTime, parseErr := time.Parse("2006-01-02T15:04:05.000Z", clockResponseData.TimeReading)
+	parsedTime, parseErr := time.Parse(clockLayout, clockResponseData.TimeReading)
 	if parseErr != nil {
 		// try getting a day
 		parsedTime, parseErr = time.Parse("2006-01-02", clockResponseData.TimeReading)
@@ -62,24 +67,31 @@ func DiligentClerk(ctx context.Context, clockResponseData types.ClockReading, us
 		responseTelegram.Emoji = ":calendar:"
 		responseTelegram.ClockReference = "unavailable"
 	}
+	if transcript != "" {
+		// the sender's words, dated by the clock
+		span.SetAttributes(attribute.Int("genteel.telegram.length", len(transcript)))
+		responseTelegram.Message = transcript
+	}
 
-	if clerkRandErrChance1 < config.GetChaosChance("breakChance") { // somestimes it can't wait
+	if clerkRandErrChance1 < config.GetChaosChance(ctx, "breakChance") { // somestimes it can't wait
 		span.AddEvent("Break time")
+		o11y.RecordOutcome(ctx, o11y.OutcomeBreak, "DiligentClerk", "breakChance")
 		err := errors.New("clerk seems to be having a break 🫖")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
-		o11y.Logger.ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		o11y.Component(o11y.ComponentClerk).ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 
 		responseTelegram.Message = "The time is not available at this moment!!"
 		return responseTelegram, fiber.NewError(fiber.StatusTeapot, err.Error())
-	} else if clerkRandErrChance2 < config.GetChaosChance("indisposedChance") { // oh dear (if we haven't tripped before)
+	} else if clerkRandErrChance2 < config.GetChaosChance(ctx, "indisposedChance") { // oh dear (if we haven't tripped before)
 		span.AddEvent("Urgent need")
+		o11y.RecordOutcome(ctx, o11y.OutcomeIndisposed, "DiligentClerk", "indisposedChance")
 		err := errors.New("clerk seems to be indisposed 💩")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
-		o11y.Logger.ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		o11y.Component(o11y.ComponentClerk).ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 
 		responseTelegram.Message = "The time is not available at this moment!!"
 		return responseTelegram, fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
@@ -0,0 +1,175 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// topologies may well contain cycles, so we stop at some point
+const maxMeshHops = 32
+
+var meshClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+	Timeout:   30 * time.Second,
+}
+
+// TrainConductor runs the calls the topology declares for an endpoint
+func TrainConductor(ctx context.Context, endpointName string, hops int) (types.MeshReport, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "TrainConductor")
+	defer span.End()
+	span.SetAttributes(attribute.String("genteel.mesh.endpoint", endpointName))
+
+	report := types.MeshReport{
+		Endpoint:   endpointName,
+		Beacon:     config.AppName,
+		StatusCode: http.StatusOK,
+	}
+
+	endpoint, known := config.Topology.Endpoints[endpointName]
+	if !known {
+		report.StatusCode = http.StatusNotFound
+		report.Error = "no such endpoint in the topology"
+		return report, fiber.NewError(fiber.StatusNotFound, report.Error)
+	}
+	if hops > maxMeshHops {
+		err := errors.New("too many hops, the topology seems to be looping 🌀")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		report.StatusCode = http.StatusLoopDetected
+		report.Error = err.Error()
+		return report, fiber.NewError(fiber.StatusLoopDetected, err.Error())
+	}
+
+	o11y.Logger.DebugContext(ctx, "Conductor dispatching "+strconv.Itoa(len(endpoint.Calls))+" calls for "+endpointName+" 🚂", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+	report.Calls = make([]types.MeshReport, len(endpoint.Calls))
+	if endpoint.Mode == "parallel" {
+		var wg sync.WaitGroup
+		for i, edge := range endpoint.Calls {
+			wg.Go(func() {
+				report.Calls[i] = travelEdge(ctx, edge, hops)
+			})
+		}
+		wg.Wait()
+	} else {
+		for i, edge := range endpoint.Calls {
+			report.Calls[i] = travelEdge(ctx, edge, hops)
+			if report.Calls[i].Error != "" {
+				// no point in going on
+				report.Calls = report.Calls[:i+1]
+				break
+			}
+		}
+	}
+
+	for _, call := range report.Calls {
+		if call.Error != "" {
+			err := errors.New("call to " + call.Beacon + " failed")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			report.StatusCode = http.StatusBadGateway
+			report.Error = err.Error()
+			return report, fiber.NewError(fiber.StatusBadGateway, err.Error())
+		}
+	}
+
+	return report, nil
+}
+
+// travelEdge makes a single call of the topology
+func travelEdge(ctx context.Context, edge types.TopologyEdge, hops int) types.MeshReport {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "TopologyEdge")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("genteel.mesh.beacon", edge.Beacon),
+		attribute.String("genteel.mesh.endpoint", edge.Endpoint),
+	)
+
+	edgeReport := types.MeshReport{
+		Endpoint: edge.Endpoint,
+		Beacon:   edge.Beacon,
+	}
+
+	// the line takes its time, unless nobody is waiting anymore
+	select {
+	case <-time.After(edge.Delay):
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		span.SetStatus(codes.Error, ctx.Err().Error())
+		edgeReport.Error = ctx.Err().Error()
+		return edgeReport
+	}
+
+	if rand.Float64() < edge.ErrorChance {
+		err := errors.New("the line to " + edge.Beacon + " is down ⚡")
+		span.AddEvent("Line down")
+		o11y.RecordOutcome(ctx, o11y.OutcomeLineDown, "TopologyEdge", edge.Beacon+"/mesh/"+edge.Endpoint)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Logger.ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		edgeReport.StatusCode = http.StatusServiceUnavailable
+		edgeReport.Error = err.Error()
+		return edgeReport
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", edge.Beacon+"/mesh/"+edge.Endpoint, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		edgeReport.Error = err.Error()
+		return edgeReport
+	}
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+	req.Header.Set(HopHeader, strconv.Itoa(hops+1))
+
+	// Inject TraceParent to Context
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := meshClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Logger.ErrorContext(ctx, "Error calling "+edge.Beacon+"!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		edgeReport.Error = err.Error()
+		return edgeReport
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&edgeReport); err != nil {
+		span.RecordError(err)
+		edgeReport.Error = "unreadable answer: " + err.Error()
+	}
+	// the answer names the downstream's own beacon, we report the edge we called
+	edgeReport.Beacon = edge.Beacon
+	edgeReport.Endpoint = edge.Endpoint
+	edgeReport.StatusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest && edgeReport.Error == "" {
+		edgeReport.Error = resp.Status
+	}
+	if edgeReport.Error != "" {
+		span.SetStatus(codes.Error, edgeReport.Error)
+	}
+	return edgeReport
+}
@@ -8,69 +8,443 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/schildwaechter/genteelbeacon/internal/beaconrpc"
 	"github.com/schildwaechter/genteelbeacon/internal/config"
 	"github.com/schildwaechter/genteelbeacon/internal/o11y"
 	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// LocalClock is the name of the reading taken without a clock
+	LocalClock = "local"
+	// how the clock tells the time
+	clockLayout = "2006-01-02T15:04:05.000Z"
 )
 
-// NimbleCourier checks the remote clock
-func NimbleCourier(ctx context.Context, clock string) (types.ClockReading, error) {
-	_, span := otel.Tracer(config.AppName).Start(ctx, "NimbleCourier")
+var (
+	// one client for all calls to the clock, faults are injected below the tracing
+	courierClient = sync.OnceValue(func() *http.Client {
+		return &http.Client{
+			Transport: otelhttp.NewTransport(&faultyTransport{base: http.DefaultTransport, service: "NimbleCourier", gate: "courierFault"}),
+		}
+	})
+	errBreakerOpen = errors.New("circuit breaker open")
+	// round-robin position across the clocks
+	clockTurn atomic.Uint64
+	// the clients of clocks reached via gRPC, by target
+	grpcClocks   = make(map[string]beaconrpc.BeaconClient)
+	grpcClocksMu sync.Mutex
+)
+
+// LocalReading is today's date, as far as we know without a clock
+func LocalReading() types.ClockReading {
+	return types.ClockReading{
+		TimeReading: time.Now().UTC().Format("2006-01-02"),
+		ClockName:   LocalClock,
+	}
+}
+
+// NimbleCourier checks the remote clocks as the strategy says, with retries and circuit breakers,
+// falling back to the local reading if no clock can be reached
+func NimbleCourier(ctx context.Context, clocks []string) (_ types.ClockReading, err error) {
+	spanCtx, span := otel.Tracer(config.AppName).Start(ctx, "NimbleCourier")
 	defer span.End()
+	defer func(start time.Time) {
+		o11y.RecordServiceDuration(spanCtx, "NimbleCourier", time.Since(start), err)
+	}(time.Now())
+	LatencyGate(spanCtx, "NimbleCourier", "courierLatency")
+	span.SetAttributes(
+		attribute.StringSlice("genteel.courier.clocks", clocks),
+		attribute.String("genteel.courier.strategy", config.Courier.Strategy),
+	)
+
+	o11y.Component(o11y.ComponentCourier).DebugContext(ctx, fmt.Sprintf("Courier checking %v 🐦", clocks), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 
-	// we need to make calls out
-	client := &http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	// every request starts with the next clock
+	turn := int(clockTurn.Add(1)-1) % len(clocks)
+	inTurn := slices.Concat(clocks[turn:], clocks[:turn])
+
+	var clockResponseData types.ClockReading
+	var answered []string
+	switch config.Courier.Strategy {
+	case config.ClockHedged:
+		clockResponseData, answered, err = hedgedRound(spanCtx, span, inTurn)
+	case config.ClockQuorum:
+		clockResponseData, answered, err = quorumRound(spanCtx, span, inTurn)
+	case config.ClockRandom:
+		rand.Shuffle(len(inTurn), func(i, j int) { inTurn[i], inTurn[j] = inTurn[j], inTurn[i] })
+		clockResponseData, answered, err = courierRounds(spanCtx, span, inTurn)
+	default:
+		clockResponseData, answered, err = courierRounds(spanCtx, span, inTurn)
 	}
+	if err == nil {
+		span.SetAttributes(
+			attribute.StringSlice("genteel.courier.answered", answered),
+			attribute.String("genteel.courier.reference", clockResponseData.ClockName),
+		)
+		return clockResponseData, nil
+	}
+	span.RecordError(err)
+
+	if config.Courier.Fallback && ctx.Err() == nil {
+		span.AddEvent("Falling back to the local date")
+		o11y.RecordCourierDecision(spanCtx, o11y.DecisionFallback, LocalClock)
+		o11y.Component(o11y.ComponentCourier).WarnContext(ctx, "Clock can't be reached, going with the local date: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		return LocalReading(), nil
+	}
+
+	span.SetStatus(codes.Error, err.Error())
+	o11y.Component(o11y.ComponentCourier).ErrorContext(ctx, "Error checking clock: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	clockResponseData = types.ClockReading{
+		TimeReading: "Error checking clock!",
+		ClockName:   "unknown",
+	}
+	if errors.Is(err, errBreakerOpen) {
+		return clockResponseData, fiber.NewError(fiber.StatusServiceUnavailable, "The clock is not being asked for the moment")
+	}
+	return clockResponseData, fiber.NewError(fiber.StatusBadGateway, "The clock can't be reached")
+}
+
+// clockEvent attributes an event on the courier's span to the clock
+func clockEvent(span trace.Span, name string, clock string, attributes ...attribute.KeyValue) {
+	span.AddEvent(name, trace.WithAttributes(append(attributes, attribute.String("genteel.courier.clock", clock))...))
+}
 
-	o11y.Logger.DebugContext(ctx, "Courier checking "+clock+" 🐦", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+// admitted asks the clock's breaker whether the clock may be asked
+func admitted(ctx context.Context, span trace.Span, clock string) bool {
+	allowed, probe := breakerFor(clock).allow()
+	if !allowed {
+		clockEvent(span, "Circuit breaker open, not asking the clock", clock)
+		o11y.RecordCourierDecision(ctx, o11y.DecisionBreakerRejected, clock)
+		return false
+	}
+	if probe {
+		clockEvent(span, "Circuit breaker half-open, probing the clock", clock)
+		o11y.RecordCourierDecision(ctx, o11y.DecisionBreakerProbe, clock)
+	}
+	return true
+}
 
+// errand asks an admitted clock once and tells its breaker how it went
+func errand(ctx context.Context, span trace.Span, clock string, attempt int) (types.ClockReading, error) {
+	errandCtx, errandSpan := otel.Tracer(config.AppName).Start(ctx, "CourierErrand", trace.WithAttributes(
+		attribute.String("genteel.courier.clock", clock),
+		attribute.Int("genteel.courier.attempt", attempt),
+	))
+	defer errandSpan.End()
+
+	breaker := breakerFor(clock)
+	clockResponseData, err := checkClock(errandCtx, clock)
+	if err != nil {
+		errandSpan.RecordError(err)
+		errandSpan.SetStatus(codes.Error, err.Error())
+	} else {
+		errandSpan.SetAttributes(attribute.String("genteel.courier.reference", clockResponseData.ClockName))
+	}
+	if err != nil && ctx.Err() != nil {
+		// not the clock's fault, we were told to stop
+		breaker.abandon()
+		return clockResponseData, err
+	}
+	switch breaker.record(err == nil) {
+	case BreakerOpen:
+		clockEvent(span, "Circuit breaker opened", clock)
+		o11y.RecordCourierDecision(ctx, o11y.DecisionBreakerOpened, clock)
+		o11y.Component(o11y.ComponentCourier).WarnContext(ctx, "Circuit breaker for "+clock+" opened 🔌")
+	case BreakerClosed:
+		clockEvent(span, "Circuit breaker closed", clock)
+		o11y.RecordCourierDecision(ctx, o11y.DecisionBreakerClosed, clock)
+		o11y.Component(o11y.ComponentCourier).InfoContext(ctx, "Circuit breaker for "+clock+" closed again")
+	}
+	var netErr net.Error
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) || status.Code(err) == grpccodes.DeadlineExceeded) {
+		clockEvent(span, "Clock timed out", clock, attribute.Int("genteel.courier.attempt", attempt))
+		o11y.RecordCourierDecision(ctx, o11y.DecisionTimeout, clock)
+	}
+	return clockResponseData, err
+}
+
+// courierRounds asks the clocks in turn until one answers or the retries are used up,
+// skipping those whose breaker is open
+func courierRounds(ctx context.Context, span trace.Span, clocks []string) (types.ClockReading, []string, error) {
+	backoff := config.Courier.BackoffInitial
+	next := 0
+	for attempt := 1; ; attempt++ {
+		clock := ""
+		for range clocks {
+			candidate := clocks[next%len(clocks)]
+			next++
+			if admitted(ctx, span, candidate) {
+				clock = candidate
+				break
+			}
+		}
+		if clock == "" {
+			return types.ClockReading{}, nil, errBreakerOpen
+		}
+
+		clockResponseData, err := errand(ctx, span, clock, attempt)
+		if err == nil {
+			return clockResponseData, []string{clock}, nil
+		}
+		if ctx.Err() != nil || attempt > config.Courier.Retries || !retryable(err) {
+			return clockResponseData, nil, err
+		}
+
+		// exponential backoff with jitter, so the courier does not pester the clocks in lockstep with others
+		wait := backoff/2 + rand.N(backoff/2+1)
+		backoff = min(2*backoff, config.Courier.BackoffMax)
+		clockEvent(span, "Retrying", clock,
+			attribute.Int("genteel.courier.attempt", attempt),
+			attribute.String("genteel.courier.backoff", wait.String()),
+			attribute.String("genteel.courier.error", err.Error()),
+		)
+		o11y.RecordCourierDecision(ctx, o11y.DecisionRetry, clock)
+		o11y.Component(o11y.ComponentCourier).DebugContext(ctx, fmt.Sprintf("Asking again in %s after %s: %s", wait, clock, err))
+		select {
+		case <-ctx.Done():
+			return clockResponseData, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// clockAnswer is what came back from a clock
+type clockAnswer struct {
+	clock   string
+	reading types.ClockReading
+	err     error
+}
+
+// hedgedRound asks the clocks one after another, each time the previous takes longer than the hedge delay
+// or fails, and goes with the fastest answer
+func hedgedRound(ctx context.Context, span trace.Span, clocks []string) (types.ClockReading, []string, error) {
+	// the slower clocks are told to stop
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make(chan clockAnswer, len(clocks))
+	launched, inFlight := 0, 0
+	// launch asks the next clock whose breaker admits it, as a hedge if the one before takes too long
+	launch := func(hedging bool) {
+		for launched < len(clocks) {
+			clock := clocks[launched]
+			launched++
+			if !admitted(ctx, span, clock) {
+				continue
+			}
+			if hedging {
+				clockEvent(span, "Hedging", clock)
+				o11y.RecordCourierDecision(ctx, o11y.DecisionHedge, clock)
+			}
+			inFlight++
+			go func() {
+				reading, err := errand(ctx, span, clock, 1)
+				answers <- clockAnswer{clock: clock, reading: reading, err: err}
+			}()
+			return
+		}
+	}
+
+	launch(false)
+	err := errBreakerOpen
+	for inFlight > 0 {
+		var hedge <-chan time.Time
+		if launched < len(clocks) {
+			hedge = time.After(config.Courier.HedgeDelay)
+		}
+		select {
+		case answer := <-answers:
+			inFlight--
+			if answer.err == nil {
+				clockEvent(span, "Fastest clock answered", answer.clock)
+				return answer.reading, []string{answer.clock}, nil
+			}
+			err = answer.err
+			launch(false)
+		case <-hedge:
+			launch(true)
+		}
+	}
+	return types.ClockReading{}, nil, err
+}
+
+// quorumRound asks all clocks at once and goes with the median reading, if enough of them answer.
+// Clocks too far off the median are reported as skewed.
+func quorumRound(ctx context.Context, span trace.Span, clocks []string) (types.ClockReading, []string, error) {
+	answers := make(chan clockAnswer, len(clocks))
+	for _, clock := range clocks {
+		go func() {
+			reading, _, err := courierRounds(ctx, span, []string{clock})
+			answers <- clockAnswer{clock: clock, reading: reading, err: err}
+		}()
+	}
+
+	type timedAnswer struct {
+		clockAnswer
+		time time.Time
+	}
+	var timed []timedAnswer
+	err := errBreakerOpen
+	for range clocks {
+		answer := <-answers
+		if answer.err == nil {
+			var parsedTime time.Time
+			parsedTime, answer.err = time.Parse(clockLayout, answer.reading.TimeReading)
+			if answer.err == nil {
+				timed = append(timed, timedAnswer{clockAnswer: answer, time: parsedTime})
+				continue
+			}
+			clockEvent(span, "Unreadable clock reading", answer.clock)
+		}
+		err = answer.err
+	}
+	if len(timed) < config.Courier.Quorum {
+		return types.ClockReading{}, nil, fmt.Errorf("only %d of %d clocks answered, %d needed: %w", len(timed), len(clocks), config.Courier.Quorum, err)
+	}
+
+	slices.SortFunc(timed, func(a, b timedAnswer) int { return a.time.Compare(b.time) })
+	median := timed[(len(timed)-1)/2]
+	answered := make([]string, len(timed))
+	names := make([]string, len(timed))
+	var maxSkew time.Duration
+	for i, answer := range timed {
+		answered[i] = answer.clock
+		names[i] = answer.reading.ClockName
+		skew := answer.time.Sub(median.time).Abs()
+		maxSkew = max(maxSkew, skew)
+		if skew > config.Courier.MaxSkew {
+			clockEvent(span, "Clock skew", answer.clock,
+				attribute.String("genteel.courier.reference", answer.reading.ClockName),
+				attribute.String("genteel.courier.skew", answer.time.Sub(median.time).String()),
+			)
+			o11y.RecordCourierDecision(ctx, o11y.DecisionSkew, answer.clock)
+			o11y.Component(o11y.ComponentCourier).WarnContext(ctx, fmt.Sprintf("Clock %s is off by %s ⏱️", answer.clock, answer.time.Sub(median.time)))
+		}
+	}
+	span.SetAttributes(attribute.String("genteel.courier.skew", maxSkew.String()))
+
+	return types.ClockReading{
+		TimeReading: median.reading.TimeReading,
+		ClockName:   strings.Join(names, ", "),
+	}, answered, nil
+}
+
+// clockError is an answer of the clock that is not a reading
+type clockError struct {
+	status int
+}
+
+func (e clockError) Error() string {
+	return fmt.Sprintf("clock answered %d", e.status)
+}
+
+// retryable tells whether asking again may help, the clock refusing the request won't change its mind
+func retryable(err error) bool {
+	var statusErr clockError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= 500 || statusErr.status == http.StatusTooManyRequests
+	}
+	switch status.Code(err) {
+	case grpccodes.InvalidArgument, grpccodes.NotFound, grpccodes.PermissionDenied, grpccodes.Unauthenticated,
+		grpccodes.FailedPrecondition, grpccodes.Unimplemented:
+		return false
+	}
+	return true
+}
+
+// grpcClock is the client for a clock reached via gRPC, connecting once
+func grpcClock(target string) (beaconrpc.BeaconClient, error) {
+	grpcClocksMu.Lock()
+	defer grpcClocksMu.Unlock()
+	if client, ok := grpcClocks[target]; ok {
+		return client, nil
+	}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	grpcClocks[target] = beaconrpc.NewBeaconClient(conn)
+	return grpcClocks[target], nil
+}
+
+// checkClock asks the clock once, within the courier's timeout, via gRPC for grpc:// clocks
+func checkClock(ctx context.Context, clock string) (types.ClockReading, error) {
 	var clockResponseData types.ClockReading
 
+	ctx, cancel := context.WithTimeout(ctx, config.Courier.Timeout)
+	defer cancel()
+
+	if target, ok := strings.CutPrefix(clock, "grpc://"); ok {
+		client, err := grpcClock(target)
+		if err != nil {
+			return clockResponseData, err
+		}
+		reading, err := client.Timestamp(ctx, &beaconrpc.TimestampRequest{})
+		if err != nil {
+			return clockResponseData, err
+		}
+		clockResponseData = types.ClockReading{TimeReading: reading.GetTimeReading(), ClockName: reading.GetClockName()}
+		if clockResponseData.TimeReading == "" {
+			return clockResponseData, errors.New("clock reading without a time")
+		}
+		return clockResponseData, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", clock+"/timestamp", nil)
 	if err != nil {
-		span.RecordError(err)
-		o11y.Logger.ErrorContext(ctx, "Error creating clock request!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
-		clockResponseData = types.ClockReading{
-			TimeReading: "Error creating clock request!",
-			ClockName:   "unknown",
-		}
 		return clockResponseData, err
 	}
 
 	// Inject TraceParent to Context
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := client.Do(req)
+	resp, err := courierClient().Do(req)
 	if err != nil {
-		span.RecordError(err)
-		o11y.Logger.ErrorContext(ctx, "Error checking clock!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
-		clockResponseData = types.ClockReading{
-			TimeReading: "Error checking clock!",
-			ClockName:   "unknown",
-		}
-
 		return clockResponseData, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return clockResponseData, clockError{status: resp.StatusCode}
+	}
 	responseData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		span.RecordError(err)
-		o11y.Logger.ErrorContext(ctx, err.Error())
-		clockResponseData = types.ClockReading{
-			TimeReading: err.Error(),
-			ClockName:   "unknown",
-		}
-		return clockResponseData, nil
+		return clockResponseData, err
+	}
+	if err := json.Unmarshal(responseData, &clockResponseData); err != nil {
+		return clockResponseData, fmt.Errorf("unreadable clock reading: %w", err)
+	}
+	if clockResponseData.TimeReading == "" {
+		return clockResponseData, errors.New("clock reading without a time")
 	}
-	json.Unmarshal(responseData, &clockResponseData)
-
 	return clockResponseData, nil
 }
@@ -0,0 +1,119 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testClock serves the reading, or fails if there is none
+func testClock(t *testing.T, name string, reading string) string {
+	clock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reading == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(types.ClockReading{TimeReading: reading, ClockName: name})
+	}))
+	t.Cleanup(clock.Close)
+	return clock.URL
+}
+
+func TestQuorumRound(t *testing.T) {
+	policy := config.Courier
+	t.Cleanup(func() { config.Courier = policy })
+	config.Courier.Timeout = time.Second
+	config.Courier.Retries = 0
+	config.Courier.BreakerThreshold = 0
+	config.Courier.MaxSkew = 500 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		quorum       int
+		readings     map[string]string // by clock name, empty for a failing clock
+		want         string            // the reading gone with
+		wantAnswered int
+		wantErr      string
+	}{
+		{
+			name:   "median of all",
+			quorum: 2,
+			readings: map[string]string{
+				"early": "2025-03-01T12:00:00.000Z",
+				"right": "2025-03-01T12:00:01.000Z",
+				"late":  "2025-03-01T12:00:10.000Z",
+			},
+			want:         "2025-03-01T12:00:01.000Z",
+			wantAnswered: 3,
+		},
+		{
+			name:   "lower median of an even number",
+			quorum: 2,
+			readings: map[string]string{
+				"early": "2025-03-01T12:00:00.000Z",
+				"late":  "2025-03-01T12:00:01.000Z",
+				"down":  "",
+			},
+			want:         "2025-03-01T12:00:00.000Z",
+			wantAnswered: 2,
+		},
+		{
+			name:   "fewer answers than the quorum",
+			quorum: 2,
+			readings: map[string]string{
+				"right": "2025-03-01T12:00:00.000Z",
+				"down":  "",
+				"out":   "",
+			},
+			wantErr: "only 1 of 3 clocks answered, 2 needed",
+		},
+		{
+			name:   "unreadable readings don't count",
+			quorum: 2,
+			readings: map[string]string{
+				"right":  "2025-03-01T12:00:00.000Z",
+				"broken": "teatime",
+			},
+			wantErr: "only 1 of 2 clocks answered, 2 needed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Courier.Quorum = tt.quorum
+			var clocks []string
+			for name, reading := range tt.readings {
+				clocks = append(clocks, testClock(t, name, reading))
+			}
+
+			reading, answered, err := quorumRound(t.Context(), trace.SpanFromContext(t.Context()), clocks)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if reading.TimeReading != tt.want {
+				t.Errorf("reading %q, want %q", reading.TimeReading, tt.want)
+			}
+			if len(answered) != tt.wantAnswered {
+				t.Errorf("answered %v, want %d clocks", answered, tt.wantAnswered)
+			}
+		})
+	}
+}
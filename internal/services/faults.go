@@ -0,0 +1,111 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// faultyTransport injects the network faults of its gate into the calls it makes
+type faultyTransport struct {
+	base    http.RoundTripper
+	service string
+	gate    string
+}
+
+// timeoutError looks like a network timeout to the client
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout (injected)" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (t *faultyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	model, faulty := config.GetFaultModel(ctx, t.gate)
+	if !faulty || rand.Float64() >= model.Chance {
+		return t.base.RoundTrip(req)
+	}
+
+	// the span is that of the HTTP call
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("Fault injected", trace.WithAttributes(
+		attribute.String("genteel.fault.gate", t.gate),
+		attribute.String("genteel.fault", model.Fault),
+	))
+	o11y.RecordOutcome(ctx, o11y.OutcomeFault, t.service, t.gate)
+	o11y.Logger.DebugContext(ctx, "Injecting "+model.Fault+" into call to "+req.URL.Host+" 🔌", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+	switch model.Fault {
+	case "drop":
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	case "status":
+		body := `{"error":"injected ` + strconv.Itoa(model.Status) + `"}`
+		return &http.Response{
+			Status:        strconv.Itoa(model.Status) + " " + http.StatusText(model.Status),
+			StatusCode:    model.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(bytes.NewBufferString(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	case "dnsdelay":
+		// looking up the name takes ages, then all is well
+		select {
+		case <-time.After(model.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return t.base.RoundTrip(req)
+	case "timeout":
+		select {
+		case <-time.After(model.Delay):
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// the remaining faults mangle a real answer
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	switch model.Fault {
+	case "truncate":
+		body = body[:len(body)/2]
+	case "corrupt":
+		for range max(len(body)/8, 1) {
+			if len(body) > 0 {
+				body[rand.IntN(len(body))] = byte(rand.IntN(256))
+			}
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	// the length stays as announced, a truncated body is cut short on the wire as well
+	return resp, nil
+}
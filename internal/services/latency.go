@@ -0,0 +1,42 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LatencyGate holds the service up as the gate's flag demands, recorded on the current span
+func LatencyGate(ctx context.Context, service string, gate string) {
+	model, delayed := config.GetLatencyModel(ctx, gate)
+	if !delayed || rand.Float64() >= model.Chance {
+		return
+	}
+	delay := model.Sample()
+
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("Latency injected", trace.WithAttributes(
+		attribute.String("genteel.latency.gate", gate),
+		attribute.String("genteel.latency.distribution", model.Distribution),
+		attribute.Int64("genteel.latency.ms", delay.Milliseconds()),
+	))
+	o11y.RecordOutcome(ctx, o11y.OutcomeLatency, service, gate)
+	o11y.Logger.DebugContext(ctx, "Held up at "+gate+" for "+delay.String()+" ⏳", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+	// no point in waiting for someone who left
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+}
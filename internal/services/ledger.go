@@ -0,0 +1,124 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/ledger"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNoLedger is returned when we don't keep a ledger
+var ErrNoLedger = errors.New("no ledger kept")
+
+var ledgerStore ledger.Store
+
+// OpenLedger sets up the configured store for the telegrams sent
+func OpenLedger() error {
+	var err error
+	if ledgerStore, err = ledger.New(); err != nil {
+		return err
+	}
+	o11y.Component(o11y.ComponentLedger).Info("Keeping the ledger in " + ledgerStore.System())
+	return nil
+}
+
+// CloseLedger lets go of the store, once nobody writes telegrams anymore
+func CloseLedger() {
+	if ledgerStore == nil {
+		return
+	}
+	if err := ledgerStore.Close(); err != nil {
+		o11y.Component(o11y.ComponentLedger).Error("Can't close the ledger: " + err.Error())
+	}
+}
+
+// consultLedger runs the operation on the store in a database span, held up as the ledgerLatency flag demands
+func consultLedger(ctx context.Context, operation string, query func(span trace.Span) error) error {
+	if ledgerStore == nil {
+		return ErrNoLedger
+	}
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "Ledger"+operation, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	start := time.Now()
+	span.SetAttributes(
+		attribute.String("db.system.name", ledgerStore.System()),
+		attribute.String("db.collection.name", "telegrams"),
+		attribute.String("db.operation.name", operation),
+	)
+
+	// a slow query, as far as anyone can tell
+	LatencyGate(ctx, "Ledger"+operation, "ledgerLatency")
+	err := query(span)
+	if errors.Is(err, ledger.ErrNotFound) {
+		// not finding a telegram is a perfectly fine answer
+		o11y.RecordServiceDuration(ctx, "Ledger"+operation, time.Since(start), nil)
+		return err
+	}
+	o11y.RecordServiceDuration(ctx, "Ledger"+operation, time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Component(o11y.ComponentLedger).ErrorContext(ctx, "Ledger "+operation+" failed: "+err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	}
+	return err
+}
+
+// RecordTelegram enters the telegram written since started in the ledger, failed if there was an error,
+// abandoned if ctx was given up on
+func RecordTelegram(ctx context.Context, requestID string, telegram types.Telegram, started time.Time, telegramErr error) {
+	entry := types.LedgerEntry{
+		Identifier:     requestID,
+		Message:        telegram.Message,
+		ClockReference: telegram.ClockReference,
+		Timestamp:      telegram.Timestamp,
+		Started:        started,
+		Status:         ledger.StatusSent,
+	}
+	if telegramErr != nil {
+		entry.Status = ledger.StatusFailed
+		entry.Error = telegramErr.Error()
+		if ctx.Err() != nil {
+			entry.Status = ledger.StatusAbandoned
+		}
+	}
+	// the telegram goes out anyway, the span tells if it wasn't recorded
+	_ = consultLedger(ctx, "Record", func(span trace.Span) error {
+		span.SetAttributes(attribute.String("genteel.ledger.status", entry.Status))
+		return ledgerStore.Record(entry)
+	})
+}
+
+// ListTelegrams finds the telegrams in the ledger matching the filter, the newest first
+func ListTelegrams(ctx context.Context, filter ledger.Filter) (entries []types.LedgerEntry, err error) {
+	err = consultLedger(ctx, "List", func(span trace.Span) error {
+		if entries, err = ledgerStore.List(filter); err != nil {
+			return err
+		}
+		span.SetAttributes(attribute.Int("db.response.returned_rows", len(entries)))
+		return nil
+	})
+	return entries, err
+}
+
+// LookupTelegram finds the telegram with the identifier in the ledger, ledger.ErrNotFound if it isn't there
+func LookupTelegram(ctx context.Context, identifier string) (entry types.LedgerEntry, err error) {
+	err = consultLedger(ctx, "Lookup", func(span trace.Span) error {
+		entry, err = ledgerStore.Lookup(identifier)
+		return err
+	})
+	return entry, err
+}
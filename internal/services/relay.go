@@ -0,0 +1,123 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+	"github.com/schildwaechter/genteelbeacon/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers used to follow a request through a chain of relays
+const (
+	HopHeader   = "X-Genteel-Hops"
+	RelayHeader = "X-Genteel-Relay"
+)
+
+var (
+	// round-robin position across the upstreams
+	relayTurn atomic.Uint64
+	// one client for all relaying, created once the configuration is known
+	relayClient = sync.OnceValue(func() *http.Client {
+		return &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   config.RelayTimeout,
+		}
+	})
+)
+
+// PneumaticRelay forwards a request to one of the upstream beacons and returns its answer.
+// Upstreams are taken in turn, the next one is tried if an upstream can't be reached.
+func PneumaticRelay(ctx context.Context, path string, header http.Header) (types.RelayResponse, error) {
+	ctx, span := otel.Tracer(config.AppName).Start(ctx, "PneumaticRelay")
+	defer span.End()
+
+	var relayResponse types.RelayResponse
+
+	// count the hops so far, refuse to go around in circles
+	hops, _ := strconv.Atoi(header.Get(HopHeader))
+	hops++
+	span.SetAttributes(attribute.Int("genteel.relay.hops", hops))
+	if hops > config.RelayMaxHops {
+		err := errors.New("too many hops, the tubes seem to be looping 🌀")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.Logger.ErrorContext(ctx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		return relayResponse, fiber.NewError(fiber.StatusLoopDetected, err.Error())
+	}
+	relayChain := config.AppName
+	if previous := header.Get(RelayHeader); previous != "" {
+		relayChain = previous + ", " + config.AppName
+	}
+
+	start := relayTurn.Add(1) - 1
+	var lastErr error
+	for i := range config.RelayUpstreams {
+		upstream := config.RelayUpstreams[(start+uint64(i))%uint64(len(config.RelayUpstreams))]
+		o11y.Logger.DebugContext(ctx, "Relaying "+path+" to "+upstream+" 📯", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+
+		req, err := http.NewRequestWithContext(ctx, "GET", upstream+path, nil)
+		if err != nil {
+			lastErr = err
+			span.RecordError(err)
+			continue
+		}
+		req.Header = header.Clone()
+		req.Header.Set(HopHeader, strconv.Itoa(hops))
+		req.Header.Set(RelayHeader, relayChain)
+
+		// Inject TraceParent to Context
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := relayClient().Do(req)
+		if err != nil {
+			lastErr = err
+			span.RecordError(err)
+			span.AddEvent("Upstream unreachable", trace.WithAttributes(attribute.String("genteel.relay.upstream", upstream)))
+			o11y.Logger.WarnContext(ctx, "Upstream "+upstream+" unreachable!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			span.RecordError(err)
+			continue
+		}
+
+		relayResponse = types.RelayResponse{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        body,
+			Upstream:    upstream,
+		}
+		span.SetAttributes(
+			attribute.String("genteel.relay.upstream", upstream),
+			attribute.Int("http.response.status_code", resp.StatusCode),
+		)
+		return relayResponse, nil
+	}
+
+	span.SetStatus(codes.Error, "no upstream reachable")
+	o11y.Logger.ErrorContext(ctx, "No upstream reachable!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+	return relayResponse, fiber.NewError(fiber.StatusBadGateway, lastErr.Error())
+}
@@ -0,0 +1,269 @@
+// Schildwächter's Genteel Beacon
+// Copyright Carsten Thiel 2025-2026
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/schildwaechter/genteelbeacon/internal/config"
+	"github.com/schildwaechter/genteelbeacon/internal/o11y"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Resource is a consumable such as ink or grease, used up by requests and recovering over time
+type Resource struct {
+	config.ResourceSpec
+	// using atomic for thread-safe access
+	level   atomic.Int64
+	changes chan int64
+	// the next consultations trip, no matter the level
+	forcedTrips atomic.Int64
+}
+
+var (
+	resources []*Resource
+	// held by the agitator to jam the monitors
+	monitorsJam sync.RWMutex
+	jammed      atomic.Bool
+)
+
+// InitResources sets up all declared resources with their channels
+func InitResources() {
+	for _, spec := range config.Resources() {
+		resources = append(resources, &Resource{
+			ResourceSpec: spec,
+			changes:      make(chan int64),
+		})
+	}
+}
+
+// Resources returns all resources
+func Resources() []*Resource {
+	return resources
+}
+
+// GetResource returns the named resource, nil if there is no such thing
+func GetResource(name string) *Resource {
+	for _, resource := range resources {
+		if resource.Name == name {
+			return resource
+		}
+	}
+	return nil
+}
+
+// Level returns the current level in a thread-safe manner
+func (r *Resource) Level() int64 {
+	return r.level.Load()
+}
+
+// SetLevel puts the resource at the level, within the model's bounds
+func (r *Resource) SetLevel(level int64) {
+	r.level.Store(min(max(level, 0), config.GetResourceModel(r.Name).Ceiling))
+}
+
+// adjustLevel changes the level, starting over if it was set in the meantime, e.g. via the admin API
+func (r *Resource) adjustLevel(adjust func(level int64) int64) {
+	for {
+		level := r.level.Load()
+		if r.level.CompareAndSwap(level, adjust(level)) {
+			return
+		}
+	}
+}
+
+// ForceTrip makes the gate trip on the next count consultations
+func (r *Resource) ForceTrip(count int64) {
+	r.forcedTrips.Add(count)
+}
+
+// forcedTrip takes one of the forced trips, if there are any left
+func (r *Resource) forcedTrip() bool {
+	for {
+		trips := r.forcedTrips.Load()
+		if trips <= 0 {
+			return false
+		}
+		if r.forcedTrips.CompareAndSwap(trips, trips-1) {
+			return true
+		}
+	}
+}
+
+// Consume uses up the resource for a single request
+func (r *Resource) Consume() {
+	r.changes <- 1
+}
+
+// StartResourceMonitors manages the levels and starts jobs to recover them periodically, until the context is done
+func StartResourceMonitors(ctx context.Context) {
+	for _, resource := range resources {
+		go func() {
+			for {
+				var change int64
+				select {
+				case <-ctx.Done():
+					return
+				case change = <-resource.changes:
+				}
+				// stuck here while jammed
+				monitorsJam.RLock()
+				monitorsJam.RUnlock()
+				model := config.GetResourceModel(resource.Name)
+				if change == -1 {
+					resource.adjustLevel(func(level int64) int64 {
+						return max(level-model.DrainRate, 0)
+					})
+				} else if change == 1 {
+					// a request may use up less or more than one unit to simulate different impact
+					consumption := model.Consumption()
+					resource.adjustLevel(func(level int64) int64 {
+						return min(level+consumption, model.Ceiling)
+					})
+				}
+			}
+		}()
+		// the intervals may change at runtime, so we check again every time
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(config.GetResourceModel(resource.Name).DrainInterval):
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case resource.changes <- -1:
+				}
+			}
+		}()
+	}
+}
+
+// JamMonitors deadlocks the resource monitors, requests consuming resources hang until unjammed
+func JamMonitors() {
+	if jammed.CompareAndSwap(false, true) {
+		monitorsJam.Lock()
+	}
+}
+
+// UnjamMonitors lets the resource monitors continue
+func UnjamMonitors() {
+	if jammed.CompareAndSwap(true, false) {
+		monitorsJam.Unlock()
+	}
+}
+
+// MonitorsResponsive tells whether the resource monitors can do their job, i.e. are not deadlocked
+func MonitorsResponsive() bool {
+	if !monitorsJam.TryRLock() {
+		return false
+	}
+	monitorsJam.RUnlock()
+	return true
+}
+
+// Saturated tells whether any resource counting towards readiness is too far gone to take on more work
+func Saturated() bool {
+	for _, resource := range resources {
+		if resource.Readiness && config.GetResourceModel(resource.Name).Saturated(resource.Level()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsultGates checks all gates of resources used by the endpoint and consumes them
+func ConsultGates(ctx context.Context, endpoint string) error {
+	for _, resource := range resources {
+		if !slices.Contains(resource.Endpoints, endpoint) {
+			continue
+		}
+		if err := resource.Gate(ctx); err != nil {
+			return err
+		}
+		resource.Consume()
+	}
+	return nil
+}
+
+// AwaitGates holds back while any resource used by the endpoint is at a level where its gate may trip,
+// up to the wait, and tells whether they recovered in time
+func AwaitGates(ctx context.Context, endpoint string, wait time.Duration) bool {
+	deadline := time.Now().Add(wait)
+	stalled := make(map[string]bool)
+	for {
+		dry := false
+		for _, resource := range resources {
+			if !slices.Contains(resource.Endpoints, endpoint) || resource.Level() < config.GetResourceModel(resource.Name).TripThreshold {
+				continue
+			}
+			dry = true
+			if !stalled[resource.Name] {
+				stalled[resource.Name] = true
+				trace.SpanFromContext(ctx).AddEvent("Awaiting " + resource.Name)
+				o11y.RecordOutcome(ctx, o11y.OutcomeStall, resource.GateName, resource.Name)
+			}
+		}
+		if !dry {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Gate checks whether the resource is too far gone
+func (r *Resource) Gate(ctx context.Context) (err error) {
+	childCtx, span := otel.Tracer(config.AppName).Start(ctx, r.GateName)
+	defer span.End()
+	defer func(start time.Time) {
+		o11y.RecordServiceDuration(childCtx, r.GateName, time.Since(start), err)
+	}(time.Now())
+	LatencyGate(childCtx, r.GateName, r.LatencyGate)
+
+	// Whether to trip (between 0 and 1)
+	tripValue := rand.Float64()
+	// The threshold to trip the gate follows the configured curve
+	currentLevel := r.Level()
+	tripThreshold := config.GetResourceModel(r.Name).TripChance(currentLevel)
+
+	o11y.Component(o11y.ComponentResources).DebugContext(childCtx, fmt.Sprintf("%s level %d - tripThreshold %f - tripValue %f", r.Name, currentLevel, tripThreshold, tripValue))
+
+	if r.forcedTrip() || tripValue < tripThreshold {
+		// this is a serious failure
+		err := errors.New(r.TripMessage)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		o11y.RecordOutcome(childCtx, o11y.OutcomeTrip, r.GateName, r.Name)
+
+		o11y.Component(o11y.ComponentResources).ErrorContext(childCtx, err.Error(), o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(childCtx, span))
+
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	} else {
+		time.Sleep(3 * time.Millisecond) // artificial span increase
+	}
+
+	return nil
+}
@@ -17,9 +17,14 @@ import (
 	"go.opentelemetry.io/otel"
 )
 
-func FocusedScribe(ctx context.Context, requestID string) (types.CallingCard, error) {
+func FocusedScribe(ctx context.Context, requestID string) (_ types.CallingCard, err error) {
 	ctx, span := otel.Tracer(config.AppName).Start(ctx, "FocusedScribe")
 	defer span.End()
+	defer func(start time.Time) {
+		o11y.RecordServiceDuration(ctx, "FocusedScribe", time.Since(start), err)
+	}(time.Now())
+
+	LatencyGate(ctx, "FocusedScribe", "scribeLatency")
 
 	var responseCallingCard types.CallingCard
 	nodeName, err := os.Hostname()
@@ -28,9 +33,10 @@ func FocusedScribe(ctx context.Context, requestID string) (types.CallingCard, er
 	}
 
 	scribeRandErrChance := rand.Float64()
-	if scribeRandErrChance < config.GetChaosChance("penDropChance") { // very rare super long delay
+	if scribeRandErrChance < config.GetChaosChance(ctx, "penDropChance") { // very rare super long delay
 		span.AddEvent("Pen search")
-		o11y.Logger.WarnContext(ctx, "Scribe dropped the pen 🔍!!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
+		o11y.RecordOutcome(ctx, o11y.OutcomePenDrop, "FocusedScribe", "penDropChance")
+		o11y.Component(o11y.ComponentScribe).WarnContext(ctx, "Scribe dropped the pen 🔍!!", o11y.LoggerTraceAttr(ctx, span), o11y.LoggerSpanAttr(ctx, span))
 		time.Sleep(3 * time.Second) // uppss...
 	} else {
 		time.Sleep(time.Duration(rand.IntN(80)+50) * time.Millisecond) // normal artificial span increase
@@ -6,6 +6,8 @@
 // Package types defines the data structures used.
 package types
 
+import "time"
+
 type Telegram struct {
 	Message        string
 	Emoji          string
@@ -17,6 +19,24 @@ type Telegram struct {
 	Timestamp      string
 }
 
+// TelegraphMessage is sent down the telegraph line, the carrier holds the sender's trace context
+type TelegraphMessage struct {
+	Message string
+	Carrier map[string]string `json:",omitempty"`
+}
+
+// LedgerEntry is what the ledger keeps of a telegram, sent or failed
+type LedgerEntry struct {
+	Identifier     string
+	Message        string
+	ClockReference string
+	Timestamp      string    // the telegram's, as told by the clock
+	Started        time.Time // when we began writing it
+	Recorded       time.Time // when it was done
+	Status         string    // sent, failed or abandoned
+	Error          string    `json:",omitempty"`
+}
+
 type ClockReading struct {
 	TimeReading string
 	ClockName   string
@@ -29,3 +49,36 @@ type CallingCard struct {
 	Signature   string
 	Identifier  string
 }
+
+type RelayResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Upstream    string
+}
+
+// Topology describes the call graph a set of beacons emulates
+type Topology struct {
+	Endpoints map[string]TopologyEndpoint `json:"endpoints"`
+}
+
+type TopologyEndpoint struct {
+	Mode  string         `json:"mode"` // sequential (default) or parallel
+	Calls []TopologyEdge `json:"calls"`
+}
+
+type TopologyEdge struct {
+	Beacon      string        `json:"beacon"`
+	Endpoint    string        `json:"endpoint"`
+	Latency     string        `json:"latency"`
+	ErrorChance float64       `json:"errorChance"`
+	Delay       time.Duration `json:"-"` // parsed Latency
+}
+
+type MeshReport struct {
+	Endpoint   string
+	Beacon     string
+	StatusCode int
+	Error      string       `json:",omitempty"`
+	Calls      []MeshReport `json:",omitempty"`
+}